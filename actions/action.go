@@ -8,10 +8,8 @@ import (
 	"github.com/peterh/liner"
 	"github.com/tikv/client-go/v2/txnkv"
 	"github.com/tikv/client-go/v2/txnkv/transaction"
-	"log"
 	"os"
 	"os/signal"
-	"strconv"
 	"strings"
 	"syscall"
 	"tikv/base"
@@ -19,20 +17,51 @@ import (
 	"time"
 )
 
+// 命令执行结果，同时作为非交互模式下的进程退出码
+const (
+	ExitOK        = 0
+	ExitNotFound  = 1
+	ExitError     = 2
+	ExitCancelled = 3
+)
+
 type TiKVClient struct {
-	Client *txnkv.Client
+	Client    *txnkv.Client
+	Config    *utils.Config
+	Profile   *utils.Profile // 当前连接的 profile
+	AssumeYes bool           // 非交互模式下跳过删除确认
+	Output    string         // 启动参数 -o 指定的默认输出格式
+
+	status int
+	kfmt   string  // 本次命令 key 的显示方式
+	vfmt   string  // 本次命令 value 的显示方式
+	cursor *cursor // 上一页 ll/find 的位置，next 从这里继续
+
+	snapshotTS uint64 // snapshot 命令固定的读取版本，0 表示读最新数据
+
+	cmdLine  string // 本次命令的原文，写入审计日志
+	opID     string // 本次命令的操作 ID，随修改记录写入日志，undo 按它恢复
+	opLogged int    // 本次命令已提交且写入日志的修改条数
+	commitTS uint64 // 最近一次提交的 commit TS
+	noLog    bool   // 本次命令带 -nolog，不写审计日志，只对这一条命令有效
+	batchSeq uint64 // 审计日志中事务的编号，多个 goroutine 提交时原子递增
+	logErr   error  // 提交之后写审计日志失败的第一个错误，命令结束时报告
 }
 type Data struct {
 	Owner       string `json:"owner"`
 	LockTime    int64  `json:"lockTime"`
 	MaxDuration int64  `json:"maxDuration"`
+	ObjectKey   string `json:"objectKey"`
 }
 
-var cmdStr []string
+const (
+	deleteBatchSize = 3000 // 删除时每个事务提交的 key 数
+	batchGetSize    = 1000 // mget 每次 BatchGet 的 key 数
+)
 
 func (c *TiKVClient) StartCmd(line *liner.State) {
 	for {
-		input, err := line.Prompt("TiKVClient> ")
+		first, err := line.Prompt(c.prompt())
 		if err != nil {
 			if errors.Is(err, liner.ErrPromptAborted) {
 				return
@@ -41,188 +70,104 @@ func (c *TiKVClient) StartCmd(line *liner.State) {
 			continue
 		}
 
-		line.AppendHistory(input)
-		cmd := strings.Fields(strings.TrimSpace(input))
-		if len(cmd) == 0 {
+		// 引号未闭合、续行或 heredoc 时继续读取后续行
+		cl, err := utils.ReadCommand(first, func() (string, error) {
+			return line.Prompt("... ")
+		})
+		if err != nil {
+			fmt.Println("input err:", err)
 			continue
 		}
-
-		cmdStr = cmd
-
-		switch cmd[0] {
-		case "get":
-			if len(cmd) < 2 {
-				fmt.Println("usage: get <key>")
-				continue
-			}
-			c.handleGet(cmd[1])
-		case "ll":
-			containLimit, limit := utils.ContainLimit(cmd)
-			containPv := utils.ContainPv(cmd)
-			if len(cmd) == 2 {
-				c.handleListAll(cmd[1], false)
-			} else if len(cmd) == 3 { // 提供前缀,有value
-				if containPv {
-					c.handleListAll(cmd[1], true)
-				} else if len(cmd) == 3 && containLimit {
-					c.handleListRange(cmd[1], "", false, limit)
-				} else {
-					c.handleListRange(cmd[1], cmd[2], false, -1)
-				}
-			} else if len(cmd) == 4 { // 有参数时范围读取,有value
-				if containLimit && containPv {
-					c.handleListRange(cmd[1], "", true, limit)
-				} else if containLimit && !containPv {
-					c.handleListRange(cmd[1], cmd[2], false, limit)
-				} else if !containLimit && containPv {
-					c.handleListRange(cmd[1], cmd[2], true, -1)
-				} else {
-					fmt.Println("usage: ll <prefixKey> [endKey] -limit=n -pv")
-				}
-			} else if len(cmd) == 5 {
-				if containPv && containLimit {
-					c.handleListRange(cmd[1], cmd[2], true, limit)
-				} else {
-					fmt.Println("usage: ll <prefixKey> [endKey] -limit=n -pv")
-				}
-			} else {
-				fmt.Println("usage: ll <prefixKey> [endKey] -limit=n -pv")
+		line.AppendHistory(cl.Line)
+		if len(cl.Tokens) == 0 {
+			// 有未看完的分页时，直接回车等同于 next
+			if c.cursor != nil {
+				cl, _ = utils.ParseCommandLine("next")
+				c.Exec(cl)
 			}
-		case "set":
-			if len(cmd) < 3 {
-				fmt.Println("usage: set <key> <value>")
-				continue
-			}
-			c.HandleSet(cmd[1], strings.Join(cmd[2:], " "))
-		case "del":
-			containNolog := utils.ContainNolog(cmd)
-			containLimit, _ := utils.ContainLimit(cmd)
-			if len(cmd) < 2 {
-				fmt.Println("usage: del <key> -nolog; del <startKey> <endKey> -nolog; del <lockKey> owner maxDuration lockTime -nolog")
-				continue
-			} else if containLimit {
-				fmt.Println("usage: del <key> -nolog; del <startKey> <endKey> -nolog; del <lockKey> owner maxDuration lockTime -nolog")
-				continue
-			} else if len(cmd) == 2 {
-				fmt.Printf("Are you sure to delete key=%s? (yes/no): \n", cmd[1])
-				var confirm string
-				_, err := fmt.Scan(&confirm)
-				if err != nil {
-					fmt.Printf("input err: %v\n", err)
-					continue
-				}
-				if confirm != "yes" {
-					continue
-				} else {
-					c.handleDelete(cmd[1], true)
-				}
-			} else if len(cmd) == 3 && containNolog {
-				fmt.Printf("Are you sure to delete key=%s? (yes/no): \n", cmd[1])
-				var confirm string
-				_, err := fmt.Scan(&confirm)
-				if err != nil {
-					fmt.Printf("input err: %v\n", err)
-					continue
-				}
-				if confirm != "yes" {
-					continue
-				}
-				c.handleDelete(cmd[1], false)
-			} else if len(cmd) == 3 && !containNolog {
-				c.handleDelRange(cmd[1], cmd[2], true)
-			} else if len(cmd) == 4 && containNolog {
-				c.handleDelRange(cmd[1], cmd[2], false)
-			} else if len(cmd) == 5 {
-				lockTime, _ := strconv.Atoi(cmd[4])
-				maxDuration, _ := strconv.Atoi(cmd[3])
-				c.handleDeleteLock(cmd[1], cmd[2], int64(maxDuration), int64(lockTime), true)
-			} else if len(cmd) == 6 && containNolog {
-				lockTime, _ := strconv.Atoi(cmd[4])
-				maxDuration, _ := strconv.Atoi(cmd[3])
-				c.handleDeleteLock(cmd[1], cmd[2], int64(maxDuration), int64(lockTime), false)
-			} else {
-				fmt.Println("usage: del <key> -nolog; del <startKey> <endKey> -nolog; del <lockKey> owner maxDuration lockTime -nolog")
-			}
-
-		case "find":
-			containLimit, limit := utils.ContainLimit(cmd)
-			containPv := utils.ContainPv(cmd)
-			containValue, value := utils.ContainValue(cmd)
-			if len(cmd) < 3 {
-				fmt.Println("usage: find <prefixKey> [endKey] -value=xxx -limit=n -pv")
-				continue
-			} else if len(cmd) == 3 && containValue {
-				c.findLike(cmd[1], "", value, false, -1)
-			} else if len(cmd) == 6 && containPv && containLimit && containValue {
-				c.findLike(cmd[1], cmd[2], value, true, limit)
-			} else if len(cmd) == 4 && containLimit && containValue {
-				c.findLike(cmd[1], "", value, false, limit)
-			} else if len(cmd) == 4 && containValue && !containPv {
-				c.findLike(cmd[1], "", value, false, -1)
-			} else if len(cmd) == 4 && containValue && containPv {
-				c.findLike(cmd[1], "", value, true, -1)
-			} else if len(cmd) == 5 && containLimit && containPv && containValue {
-				c.findLike(cmd[1], "", value, true, limit)
-			} else if len(cmd) == 5 && !containPv && containLimit && containValue {
-				c.findLike(cmd[1], cmd[2], value, false, limit)
-			} else if len(cmd) == 5 && !containLimit && containPv && containValue {
-				c.findLike(cmd[1], cmd[2], value, true, -1)
-			} else {
-				fmt.Println("usage: find <prefixKey> [endKey] -value=xxx -limit=n -pv")
-			}
-		case "exit":
+			continue
+		}
+		if _, quit := c.Exec(cl); quit {
 			return
-		case "count":
-			containValue, value := utils.ContainValue(cmd)
-			if len(cmd) < 2 {
-				fmt.Println("usage: count <prefixKey> [endKey]")
-				continue
-			} else if len(cmd) == 3 && containValue {
-				c.handleCount(cmd[1], "", value)
-			} else if len(cmd) == 4 && containValue {
-				c.handleCount(cmd[1], cmd[2], value)
-			} else if len(cmd) == 2 {
-				c.handleCount(cmd[1], "", "")
-			} else if len(cmd) == 3 && !containValue {
-				c.handleCount(cmd[1], cmd[2], "")
-			} else {
-				fmt.Println("usage: count <prefixKey> [endKey]")
-			}
-		case "version":
-			c.handleVersion()
-		case "fd":
-			containLimit, limit := utils.ContainLimit(cmd)
-			containValue, value := utils.ContainValue(cmd)
-			containNolog := utils.ContainNolog(cmd)
-			if len(cmd) < 3 {
-				fmt.Println("usage: fd <prefixKey> [endKey] -value=xxx -limit=n -nolog")
-			} else if len(cmd) == 3 && containValue && !containLimit && !containNolog {
-				c.handleFindDelete(cmd[1], "", value, -1, true)
-			} else if len(cmd) == 4 && containValue && containLimit && !containNolog {
-				c.handleFindDelete(cmd[1], "", value, limit, true)
-			} else if len(cmd) == 4 && containValue && !containLimit && containNolog {
-				c.handleFindDelete(cmd[1], "", value, -1, false)
-			} else if len(cmd) == 4 && containValue && !containLimit && !containNolog {
-				c.handleFindDelete(cmd[1], cmd[2], value, -1, true)
-			} else if len(cmd) == 5 && containValue && containLimit && containNolog {
-				c.handleFindDelete(cmd[1], "", value, limit, false)
-			} else if len(cmd) == 5 && containValue && !containLimit && containNolog {
-				c.handleFindDelete(cmd[1], cmd[2], value, -1, false)
-			} else if len(cmd) == 5 && containValue && containLimit && !containNolog {
-				c.handleFindDelete(cmd[1], cmd[2], value, limit, true)
-			} else if len(cmd) == 6 && containValue && containLimit && containNolog {
-				c.handleFindDelete(cmd[1], cmd[2], value, limit, false)
+		}
+	}
+}
+
+// Exec 执行一条已拆分的命令，返回执行结果；quit 表示收到 exit 命令
+func (c *TiKVClient) Exec(cl *utils.CommandLine) (code int, quit bool) {
+	c.status = ExitOK
+	cmd := cl.Args()
+	c.cmdLine = cl.Line
+
+	if cmd[0] == "exit" {
+		return ExitOK, true
+	}
+	spec, ok := lookupCommand(cmd[0])
+	if !ok {
+		fmt.Printf("unknown command: %s, type 'help' to list commands\n", cmd[0])
+		c.status = ExitError
+		return c.status, false
+	}
+	in, err := spec.parse(cl, c.defaults())
+	if err != nil {
+		fmt.Println(err)
+		c.usage(spec.usageLine())
+		return c.status, false
+	}
+	c.kfmt, c.vfmt = in.str("kfmt"), in.str("vfmt")
+	c.opID, c.opLogged, c.commitTS, c.logErr = newOpID(), 0, 0, nil
+	c.noLog = in.bool("nolog")
+	// 修改前的值要能写进审计日志，否则不修改数据
+	if spec.modifies(in) && !c.noLog {
+		if err := base.AuditLog.Writable(); err != nil {
+			fmt.Printf("audit log is not writable: %v\n", err)
+			if _, ok := spec.flag("nolog"); ok {
+				fmt.Println("nothing was changed, add -nolog to run without the audit log")
 			} else {
-				fmt.Println("usage: fd <prefixKey> [endKey] -value=xxx -limit=n -nolog")
+				fmt.Println("nothing was changed")
 			}
-		default:
-			fmt.Println("usage: get, ll, exit, set, del, find, count, version, fd")
+			c.status = ExitError
+			return c.status, false
 		}
 	}
+	spec.run(c, in)
+	if spec.modifies(in) {
+		if err := c.writeLog(&utils.AuditRecord{Action: actionEnd, Result: resultText(c.status)}); err != nil && c.logErr == nil {
+			c.logErr = err
+		}
+	}
+	if c.logErr != nil {
+		fmt.Printf("write audit log err: %v\n", c.logErr)
+		c.status = ExitError
+	}
+	if c.opLogged > 0 {
+		fmt.Printf("op: %s, %d changes logged, revert with: undo %s\n", c.opID, c.opLogged, c.opID)
+	}
+	return c.status, false
 }
 
-func (c *TiKVClient) executeTxn(fn func(txn *transaction.KVTxn) error) error {
+// begin 开始一个事务，提交成功后 commit TS 记在 c.commitTS，供审计日志使用
+func (c *TiKVClient) begin() (*transaction.KVTxn, error) {
+	return c.beginWith(&c.commitTS)
+}
+
+// beginWith 与 begin 相同，commit TS 记在 commitTS，供多个 goroutine 各自提交时使用
+func (c *TiKVClient) beginWith(commitTS *uint64) (*transaction.KVTxn, error) {
 	txn, err := c.Client.Begin()
+	if err != nil {
+		return nil, err
+	}
+	txn.SetCommitCallback(func(info string, err error) {
+		var ti transaction.TxnInfo
+		if err == nil && json.Unmarshal([]byte(info), &ti) == nil {
+			*commitTS = ti.CommitTS
+		}
+	})
+	return txn, nil
+}
+
+func (c *TiKVClient) executeTxn(fn func(txn *transaction.KVTxn) error) error {
+	txn, err := c.begin()
 	if err != nil {
 		return fmt.Errorf("transation begin err: %w", err)
 	}
@@ -238,578 +183,401 @@ func (c *TiKVClient) executeTxn(fn func(txn *transaction.KVTxn) error) error {
 	return nil
 }
 
-func (c *TiKVClient) handleGet(key string) {
-	var result []byte
-	err := c.executeTxn(func(txn *transaction.KVTxn) error {
-		val, err := txn.Get(context.Background(), []byte(key))
-		result = val
-		return err
-	})
+// usage 打印用法并将本次命令标记为失败
+func (c *TiKVClient) usage(msg string) {
+	fmt.Println(msg)
+	c.status = ExitError
+}
+
+// confirm 删除前的二次确认，AssumeYes 时直接通过
+func (c *TiKVClient) confirm(prompt string) bool {
+	if c.AssumeYes {
+		return true
+	}
+	fmt.Println(prompt)
+	var answer string
+	if _, err := fmt.Scan(&answer); err != nil {
+		fmt.Printf("input err: %v\n", err)
+		c.status = ExitCancelled
+		return false
+	}
+	if answer != "yes" {
+		c.status = ExitCancelled
+		return false
+	}
+	return true
+}
+
+// fmtKey 按 -kfmt 显示 key，用于提示信息和日志
+func (c *TiKVClient) fmtKey(key []byte) string {
+	return utils.FormatBytes(key, c.kfmt)
+}
+
+func (c *TiKVClient) fmtValue(value []byte) string {
+	return utils.FormatBytes(value, c.vfmt)
+}
+
+// writeLog 补全操作人、集群和命令后写入审计日志，当前命令带 -nolog 时不写
+func (c *TiKVClient) writeLog(r *utils.AuditRecord) error {
+	if c.noLog {
+		return nil
+	}
+	r.Op, r.User, r.Host, r.Cmd = c.opID, auditUser, auditHost, c.cmdLine
+	if c.Profile != nil {
+		r.Profile, r.Endpoints = c.Profile.Name, c.Profile.Endpoints
+	}
+	return base.AuditLog.Write(r)
+}
+
+// rangeEnd 范围扫描的上界：未给出 end 时为 start 前缀的下一个 key，否则包含 end 本身；
+// 返回 nil 表示不设上界
+func rangeEnd(start, end []byte) []byte {
+	if len(end) == 0 {
+		return utils.PrefixEnd(start)
+	}
+	return utils.PrefixEnd(end)
+}
 
+// handleGet 读取 key 在版本 ts 的值，ts 为 0 时读最新数据
+func (c *TiKVClient) handleGet(key []byte, ts uint64, p *printer) {
+	ctx := context.Background()
+	snap, err := c.snapshot(ctx, ts)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	result, err := snap.Get(ctx, key)
 	if err != nil && strings.Contains(err.Error(), "not exist") {
-		fmt.Printf("key:%s  not exist\n", key)
+		p.missing(key)
+		p.finish(0)
+		c.status = ExitNotFound
 		return
 	}
-	fmt.Printf("value = %s\n", string(result))
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", historyErr(err, ts))
+		c.status = ExitError
+		return
+	}
+	p.row(key, result)
+	p.finish(1)
 }
 
-func (c *TiKVClient) handleListAll(start string, pv bool) {
-
-	// 创建中断信号通道
+// handleMultiGet 通过 BatchGet 按批读取多个 key，按给出的顺序输出，
+// 有 key 不存在时结果为 ExitNotFound
+func (c *TiKVClient) handleMultiGet(keys [][]byte, ts uint64, p *printer) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
 
-	err := c.executeTxn(func(txn *transaction.KVTxn) error {
-		iter, err := txn.Iter([]byte(start), []byte(utils.IncrementLastCharASCII(start)))
+	ctx := context.Background()
+	snap, err := c.snapshot(ctx, ts)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	found, missing := 0, 0
+loop:
+	for i := 0; i < len(keys); i += batchGetSize {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			break loop
+		default:
+		}
+		batch := keys[i:]
+		if len(batch) > batchGetSize {
+			batch = batch[:batchGetSize]
+		}
+		values, err := snap.BatchGet(ctx, batch)
 		if err != nil {
-			fmt.Printf("iter err: %v\n", err)
-			return nil
+			p.finish(found + missing)
+			fmt.Printf("operation failed: %v\n", historyErr(err, ts))
+			c.status = ExitError
+			return
 		}
-		defer iter.Close()
-
-		var count int
-		for iter.Valid() {
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return nil
-			default:
-				key := iter.Key()
-				value := iter.Value()
-
-				// 格式化显示
-				if pv {
-					fmt.Printf("%s", string(key))
-					fmt.Printf("	Value = %s\n", string(value))
-				} else {
-					fmt.Printf("%s\n", string(key))
-				}
-			}
-			count++
-			if err := iter.Next(); err != nil {
-				fmt.Printf("iteration failed: %v\n", err)
-				break
+		for _, key := range batch {
+			if value, ok := values[string(key)]; ok {
+				p.row(key, value)
+				found++
+			} else {
+				p.missing(key)
+				missing++
 			}
-
 		}
-		fmt.Println("-------------------")
-		fmt.Printf("total: %d\n", count)
-		return nil
-	})
-	if err != nil {
-		fmt.Printf("operation failed: %v\n", err)
+	}
+	p.finish(found + missing)
+	if p.format == formatTable {
+		fmt.Printf("found: %d, missing: %d\n", found, missing)
+	}
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
 		return
 	}
-}
-
-func (c *TiKVClient) handleListRange(key1, key2 string, pv bool, limit int) {
-
-	// 如果key2为空，则计算key1的下一个键
-	if key2 == "" {
-		key2 = utils.IncrementLastCharASCII(key1)
-	} else {
-		key2 = utils.IncrementLastCharASCII(key2)
+	if missing > 0 {
+		c.status = ExitNotFound
 	}
+}
 
+// handleListRange 按顺序列出 t 范围内的 key，limit > 0 时最多 limit 个
+func (c *TiKVClient) handleListRange(t scanTask, limit int, p *printer) {
 	// 创建中断信号通道
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
 
-	err := c.executeTxn(func(txn *transaction.KVTxn) error {
-		// 左闭右开
-		iter, err := txn.Iter([]byte(key1), []byte(key2))
-		if err != nil {
-			fmt.Printf("iter err: %v\n", err)
-			return err
-		}
-		defer iter.Close()
-
-		var count int
-		for iter.Valid() {
-			if count >= limit && limit > 0 {
-				break
-			}
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return nil
-			default:
-				key := iter.Key()
-				value := iter.Value()
-
-				// 格式化显示
-				if pv {
-					fmt.Printf("%s", string(key))
-					fmt.Printf("	Value = %s\n", string(value))
-				} else {
-					fmt.Printf("%s\n", string(key))
-				}
-			}
-			count++
-			if err := iter.Next(); err != nil {
-				fmt.Printf("iteration failed: %v\n", err)
-				break
-			}
+	t.ordered = true
+	t.keyOnly = !p.pv && (t.filter == nil || !t.filter.needValue())
+	var count int
+	err := c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			return false
+		default:
 		}
-		fmt.Println("-------------------")
-		fmt.Printf("total: %d\n", count)
-		return nil
+		p.row(key, value)
+		count++
+		return limit <= 0 || count < limit
 	})
+	p.finish(count)
+	t.filter.reportSkipped(c.fmtKey)
 	if err != nil {
 		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
 		return
 	}
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
+	}
 }
 
-func (c *TiKVClient) HandleSet(key, value string) {
+// HandleSet 写入 key，原值与新值在提交之前写入日志，可以用 undo 恢复
+func (c *TiKVClient) HandleSet(key, value []byte) {
+	b := c.newAuditBatch()
 	err := c.executeTxn(func(txn *transaction.KVTxn) error {
-		return txn.Set([]byte(key), []byte(value))
+		b.reset()
+		old, err := txn.Get(context.Background(), key)
+		if err != nil && !strings.Contains(err.Error(), "not exist") {
+			return err
+		}
+		if err := txn.Set(key, value); err != nil {
+			return err
+		}
+		b.set(key, old, value)
+		return b.write()
 	})
 
 	if err != nil {
 		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
 		return
 	}
 	fmt.Println("updated")
+	b.committed(c.commitTS)
 }
 
-func (c *TiKVClient) handleDelete(key string, nolog bool) {
-	if nolog {
-		base.GlobalLogger, base.GlobalLogFile, _ = utils.InitLog()
-	} else {
-		base.GlobalLogFile.Close()
-	}
-	var result []byte
+// handleDelete 在同一个事务中读取原值并删除，原值在提交之前写入日志，可以用 undo 恢复
+func (c *TiKVClient) handleDelete(key []byte) {
+	b := c.newAuditBatch()
+	notFound := false
 	err := c.executeTxn(func(txn *transaction.KVTxn) error {
-		val, err := txn.Get(context.Background(), []byte(key))
-		result = val
-		return err
+		b.reset()
+		val, err := txn.Get(context.Background(), key)
+		if err != nil {
+			notFound = strings.Contains(err.Error(), "not exist")
+			return err
+		}
+		if err := txn.Delete(key); err != nil {
+			return err
+		}
+		b.deleted(key, val)
+		return b.write()
 	})
-	if err != nil {
+	if notFound {
 		fmt.Println("key not exist")
+		c.status = ExitNotFound
 		return
 	}
-	err = c.executeTxn(func(txn *transaction.KVTxn) error {
-		return txn.Delete([]byte(key))
-	})
 	if err != nil {
 		fmt.Printf("delete err: %v\n", err)
+		c.status = ExitError
 		return
 	}
 	fmt.Println("deleted")
-	if base.GlobalLogger != nil {
-		base.GlobalLogger.Printf("key : %s, value : %s, cmd : %s", key, string(result), cmdStr)
-	}
+	b.committed(c.commitTS)
 }
 
-func (c *TiKVClient) findLike(key1, key2, value string, pv bool, limit int) {
-	// 创建中断信号通道
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	defer signal.Stop(sigCh)
-
-	if key2 == "" {
-		key2 = utils.IncrementLastCharASCII(key1)
-	} else {
-		key2 = utils.IncrementLastCharASCII(key2)
-	}
-	err := c.executeTxn(func(txn *transaction.KVTxn) error {
-		iter, err := txn.Iter([]byte(key1), []byte(key2))
-		if err != nil {
-			fmt.Printf("create iteration err: %v\n", err)
-			return err
-		}
-		defer iter.Close()
-
-		var count int
-		for iter.Valid() {
-			if count >= limit && limit > 0 {
-				break
-			}
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return nil
-			default:
-				k := iter.Key()
-				v := iter.Value()
-				if pv && strings.Contains(string(v), value) {
-					fmt.Printf("%s", string(k))
-					fmt.Printf("  Value = %s\n", string(v))
-					count++
-				} else {
-					if strings.Contains(string(v), value) {
-						fmt.Printf("%s\n", string(k))
-						count++
-					}
-				}
-			}
-			if err := iter.Next(); err != nil {
-				fmt.Printf("iteration failed: %v\n", err)
-				break
-			}
-		}
-		fmt.Println("-------------------")
-		fmt.Printf("total: %d\n", count)
-		return nil
-	})
-	if err != nil {
-		fmt.Printf("operation failed: %v\n", err)
-		return
-	}
+// findLike 列出满足 t.filter 的 key，table 输出时高亮匹配的部分
+func (c *TiKVClient) findLike(t scanTask, limit int, p *printer) {
+	p.highlightMatches(t.filter)
+	c.handleListRange(t, limit, p)
 }
 
-func (c *TiKVClient) handleDelRange(start, end string, nolog bool) {
-	if nolog {
-		base.GlobalLogger, base.GlobalLogFile, _ = utils.InitLog()
-	} else {
-		base.GlobalLogFile.Close()
-	}
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	defer signal.Stop(sigCh)
-	deletedTotal := 0
-	startTime := time.Now()
+// lockRange del <lockKey> <owner> <maxDuration> <lockTime> 扫描的范围，即 lockKey 下的全部锁记录
+func lockRange(key []byte) (start, end []byte) {
+	prefix := string(key) + "/Data/Lock"
+	return []byte(prefix), utils.PrefixEnd([]byte(prefix))
+}
 
-	if strings.Contains(start, ":") && strings.Contains(end, ":") {
-		s1 := start[strings.LastIndex(start, "/")+1:]
-		s2 := end[strings.LastIndex(end, "/")+1:]
-		s1 = s1[:strings.LastIndex(s1, "-")] + " " + s1[strings.LastIndex(s1, "-")+1:]
-		s2 = s2[:strings.LastIndex(s2, "-")] + " " + s2[strings.LastIndex(s2, "-")+1:]
-		startTS := utils.TimeToTS(s1)
-		endTS := utils.TimeToTS(s2)
-		start = start[0:strings.LastIndex(start, "/")+1] + strconv.Itoa(int(startTS)) + "1000000"
-		end = end[0:strings.LastIndex(end, "/")+1] + strconv.Itoa(int(endTS)) + "1000001"
-	}
-	batchSize := 3000
-	processedInBatch := 0
-	startKey := []byte(start)
-	endKey := []byte(utils.IncrementLastCharASCII(end))
-
-	fmt.Printf("Are you sure to delete? (yes/no): \n")
-	var confirm string
-	_, err := fmt.Scan(&confirm)
+// lockMatches 锁记录是否属于 owner、maxDuration 相同且在 lockTime 之后加锁，无法解析时返回错误
+func lockMatches(value []byte, owner string, maxDuration, lockTime int64) (bool, error) {
+	d, err := parseLock(value)
 	if err != nil {
-		fmt.Printf("input err: %v\n", err)
-		return
+		return false, err
 	}
-	if confirm != "yes" {
-		return
-	}
-
-	for {
-		txn, err := c.Client.Begin()
-		if err != nil {
-			fmt.Printf("transation begin err: %v\n", err)
-			return
-		}
-		defer txn.Rollback()
-
-		iter, err := txn.Iter(startKey, endKey)
-		if err != nil {
-			fmt.Printf("iter err: %v\n", err)
-			return
-		}
-		defer iter.Close()
-
-		processedInBatch = 0
-		for iter.Valid() && processedInBatch < batchSize {
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return
-			default:
-				err = txn.Delete(iter.Key())
-				if err != nil {
-					fmt.Printf("delete key=%s err: %v\n", iter.Key(), err)
-				} else {
-					deletedTotal++
-					processedInBatch++
-					if base.GlobalLogger != nil {
-						base.GlobalLogger.Printf("key : %s, value : %s, cmd : %s ", string(iter.Key()), string(iter.Value()), cmdStr)
-					}
-				}
-				if err = iter.Next(); err != nil {
-					fmt.Printf("iter.Next err: %v\n", err)
-					break
-				}
-			}
-		}
-
-		// 提交当前批次
-		if processedInBatch > 0 {
-			err = txn.Commit(context.Background())
-			if err != nil {
-				fmt.Printf("transation commit err: %v\n", err)
-				return
-			}
-			fmt.Printf("Batch deleted: %d, Total deleted: %d\n", processedInBatch, deletedTotal)
+	return lockSelected(d, owner, maxDuration, lockTime), nil
+}
 
-			if iter.Valid() {
-				startKey = iter.Key()
-			} else {
-				break
-			}
-		} else {
-			break
-		}
-	}
+func lockSelected(d *Data, owner string, maxDuration, lockTime int64) bool {
+	return d.Owner == owner && d.MaxDuration == maxDuration && d.LockTime > lockTime
+}
 
-	fmt.Println("Total deleted:", deletedTotal, "time consuming:", time.Since(startTime))
+// handleDeleteLock 删除 key 下属于 owner、maxDuration 相同且在 lockTime 之后加锁的记录，格式错误的记录跳过
+func (c *TiKVClient) handleDeleteLock(key []byte, owner string, maxDuration, lockTime int64, th *throttle) {
+	start, end := lockRange(key)
+	c.reapLocks(scanTask{start: start, end: end, parallel: defaultParallel}, func(d *Data) bool {
+		return lockSelected(d, owner, maxDuration, lockTime)
+	}, 0, th)
 }
 
-func (c *TiKVClient) handleDeleteLock(key, owner string, maxDuration, lockTime int64, nolog bool) {
-	if nolog {
-		base.GlobalLogger, base.GlobalLogFile, _ = utils.InitLog()
-	} else {
-		base.GlobalLogFile.Close()
-	}
+// handleCount 统计 t 范围内满足 t.filter 的 key 数，各 region 并发统计
+func (c *TiKVClient) handleCount(t scanTask, p *printer) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
-	deletedTotal := 0
-	startTime := time.Now()
-
-	startKey := key + "/Data/Lock"
 
-	fmt.Printf("Are you sure to delete? (yes/no): \n")
-	var confirm string
-	_, err := fmt.Scan(&confirm)
+	t.keyOnly = t.filter == nil || !t.filter.needValue()
+	var total int
+	err := c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			return false
+		default:
+		}
+		total++
+		return true
+	})
+	t.filter.reportSkipped(c.fmtKey)
 	if err != nil {
-		fmt.Printf("input err: %v\n", err)
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
 		return
 	}
-	if confirm != "yes" {
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
 		return
 	}
-
-	for {
-		txn, err := c.Client.Begin()
-		if err != nil {
-			fmt.Printf("transation begin err: %v\n", err)
-			return
-		}
-		defer txn.Rollback()
-
-		iter, err := txn.Iter([]byte(startKey), []byte(utils.IncrementLastCharASCII(startKey)))
-		if err != nil {
-			fmt.Printf("iter err: %v\n", err)
-			return
-		}
-		defer iter.Close()
-
-		batchSize := 3000
-		processedInBatch := 0
-		for iter.Valid() && processedInBatch < batchSize {
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return
-			default:
-				var result []byte
-				err = c.executeTxn(func(txn *transaction.KVTxn) error {
-					val, err := txn.Get(context.Background(), iter.Key())
-					result = val
-					return err
-				})
-				var data Data
-				err = json.Unmarshal(result, &data)
-				if err != nil {
-					log.Fatalf("JSON unmarshal: %v", err)
-				}
-
-				if strings.Compare(data.Owner, owner) == 0 && data.MaxDuration == maxDuration && data.LockTime > lockTime {
-					err = txn.Delete(iter.Key())
-					if err != nil {
-						fmt.Printf("delete key=%s err: %v\n", iter.Key(), err)
-					} else {
-						deletedTotal++
-						processedInBatch++
-						if base.GlobalLogger != nil {
-							base.GlobalLogger.Printf("key : %s, value : %s, cmd : %s", string(iter.Key()), string(iter.Value()), cmdStr)
-						}
-					}
-				}
-				if err = iter.Next(); err != nil {
-					fmt.Printf("iter.Next err: %v\n", err)
-					break
-				}
-			}
-		}
-
-		// 提交当前批次
-		if processedInBatch > 0 {
-			err = txn.Commit(context.Background())
-			if err != nil {
-				fmt.Printf("transation commit err: %v\n", err)
-				return
-			}
-			fmt.Printf("Batch deleted: %d, Total deleted: %d\n", processedInBatch, deletedTotal)
-		} else {
-			break
-		}
-	}
-	fmt.Println("Total deleted:", deletedTotal, "time consuming:", time.Since(startTime))
+	p.printTotal(total)
 }
 
-func (c *TiKVClient) handleCount(key1, key2, value string) {
+// handleTail 从范围末尾向前取最后 n 个满足 t.filter 的 key，按正序输出
+func (c *TiKVClient) handleTail(t scanTask, n int, p *printer) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
-	var lastKey []byte
-	var total int
-
-	if key2 == "" {
-		key2 = utils.IncrementLastCharASCII(key1)
-	} else {
-		key2 = utils.IncrementLastCharASCII(key2)
-	}
 
-	for {
-		txn, err := c.Client.Begin()
-		iter, err := txn.Iter([]byte(key1), []byte(key2))
-		if err != nil {
-			fmt.Printf("iter err: %v\n", err)
-			return
-		}
-		defer iter.Close()
-
-		var count int
-		for iter.Valid() && count < 10000 {
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return
-			default:
-				key := iter.Key()
-				if value != "" && strings.Contains(string(iter.Value()), value) {
-					count++
-				} else if value == "" {
-					count++
-				}
-				lastKey = key
-
-			}
-			if err := iter.Next(); err != nil {
-				fmt.Printf("iteration failed: %v\n", err)
-				break
-			}
-		}
-		//fmt.Printf("Processed %d keys in this batch\n", count)
-		total += count
-		if !iter.Valid() {
-			fmt.Println("Total: ", total)
-			return
+	t.reverse, t.ordered = true, true
+	t.keyOnly = !p.pv && (t.filter == nil || !t.filter.needValue())
+	var last []kvPair
+	err := c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			return false
+		default:
 		}
-
-		key1 = string(append(lastKey, 0))
+		last = append(last, kvPair{key: key, value: value})
+		return len(last) < n
+	})
+	t.filter.reportSkipped(c.fmtKey)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	for i := len(last) - 1; i >= 0; i-- {
+		p.row(last[i].key, last[i].value)
+	}
+	p.finish(len(last))
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
 	}
-
 }
 
 func (c *TiKVClient) handleVersion() {
 	fmt.Println("1.0.1")
 }
 
-func (c *TiKVClient) handleFindDelete(key1, key2, value string, limit int, nolog bool) {
-	if nolog {
-		base.GlobalLogger, base.GlobalLogFile, _ = utils.InitLog()
-	} else {
-		base.GlobalLogFile.Close()
-	}
-
+// handleFindDelete 删除满足 t.filter 的 key。扫描按 region 并发，
+// 删除在当前 goroutine 中按批提交；给出 limit 时按 key 顺序删除前 limit 个
+func (c *TiKVClient) handleFindDelete(t scanTask, limit int, th *throttle) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
 	defer signal.Stop(sigCh)
 
-	if key2 == "" {
-		key2 = utils.IncrementLastCharASCII(key1)
-	} else {
-		key2 = utils.IncrementLastCharASCII(key2)
+	if !c.confirm("Are you sure to delete? (yes/no): ") {
+		return
 	}
 
 	deletedTotal := 0
 	startTime := time.Now()
-
-	fmt.Printf("Are you sure to delete? (yes/no): \n")
-	var confirm string
-	_, err := fmt.Scan(&confirm)
-	if err != nil {
-		fmt.Printf("input err: %v\n", err)
-		return
-	}
-	if confirm != "yes" {
-		return
-	}
-
-	for {
-		txn, err := c.Client.Begin()
-		if err != nil {
-			fmt.Printf("transation begin err: %v\n", err)
-			return
+	var batch []kvPair
+	log := c.newAuditBatch()
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
 		}
-		defer txn.Rollback()
-
-		iter, err := txn.Iter([]byte(key1), []byte(utils.IncrementLastCharASCII(key2)))
-		if err != nil {
-			fmt.Printf("iter err: %v\n", err)
-			return
-		}
-		defer iter.Close()
-
-		batchSize := 3000
-		processedInBatch := 0
-		for iter.Valid() && processedInBatch < batchSize {
-			if deletedTotal >= limit && limit > 0 {
-				break
-			}
-			select {
-			case <-sigCh:
-				fmt.Println("\noperation cancelled")
-				return
-			default:
-				if strings.Contains(string(iter.Value()), value) {
-					err = txn.Delete(iter.Key())
-					if err != nil {
-						fmt.Printf("delete key=%s err: %v\n", iter.Key(), err)
-					} else {
-						deletedTotal++
-						processedInBatch++
-						if base.GlobalLogger != nil {
-							base.GlobalLogger.Printf("key : %s, value : %s, cmd : %s", string(iter.Key()), string(iter.Value()), cmdStr)
-						}
-					}
-				}
-				if err = iter.Next(); err != nil {
-					fmt.Printf("iter.Next err: %v\n", err)
-					break
+		err := th.execute(c.begin, func(txn *transaction.KVTxn) (int, int64, error) {
+			log.reset()
+			var size int64
+			for _, kv := range batch {
+				if err := txn.Delete(kv.key); err != nil {
+					return 0, 0, fmt.Errorf("delete key=%s err: %v", c.fmtKey(kv.key), err)
 				}
+				log.deleted(kv.key, kv.value)
+				size += int64(len(kv.key) + len(kv.value))
 			}
+			return len(batch), size, log.write()
+		})
+		if err != nil {
+			fmt.Println(err)
+			c.status = ExitError
+			return false
 		}
-
-		// 提交当前批次
-		if processedInBatch > 0 {
-			err = txn.Commit(context.Background())
-			if err != nil {
-				fmt.Printf("transation commit err: %v\n", err)
-				return
-			}
-			fmt.Printf("Batch deleted: %d, Total deleted: %d\n", processedInBatch, deletedTotal)
-		} else {
-			break
+		log.committed(c.commitTS)
+		deletedTotal += len(batch)
+		fmt.Printf("Batch deleted: %d, Total deleted: %d%s\n", len(batch), deletedTotal, th.progress())
+		batch = batch[:0]
+		return true
+	}
+
+	t.ordered = limit > 0
+	err := c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			fmt.Println("\noperation cancelled")
+			c.status = ExitCancelled
+			return false
+		default:
 		}
+		batch = append(batch, kvPair{key: key, value: value})
+		if len(batch) >= th.batchSize(deleteBatchSize) && !flush() {
+			return false
+		}
+		return limit <= 0 || deletedTotal+len(batch) < limit
+	})
+	t.filter.reportSkipped(c.fmtKey)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
 	}
-
-	fmt.Println("Total deleted:", deletedTotal, "time consuming:", time.Since(startTime))
-}
-
-func (c *TiKVClient) handleLog(nolog bool) {
-	if nolog {
-		base.GlobalLogger, base.GlobalLogFile, _ = utils.InitLog()
-	} else {
-		base.GlobalLogFile.Close()
+	if c.status != ExitOK || !flush() {
+		return
 	}
 
+	fmt.Println("Total deleted:", deletedTotal, "time consuming:", time.Since(startTime))
+	th.printSummary()
 }
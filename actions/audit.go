@@ -0,0 +1,150 @@
+package actions
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sync/atomic"
+	"tikv/base"
+	"tikv/utils"
+)
+
+// 审计记录的类型
+const (
+	actionDelete = "delete" // before 为删除前的值，在提交之前写入
+	actionSet    = "set"    // before 为写入前的值，after 为写入的值，在提交之前写入
+	actionCommit = "commit" // 同 batch 的 delete、set 已提交，commitTs 为提交的版本
+	actionPurge  = "purge"  // 开始 purge，key 到 end 为删除的范围，无法 undo
+	actionEnd    = "end"    // 修改数据的命令结束，result 为命令的结果
+)
+
+var auditUser, auditHost = utils.AuditIdentity()
+
+func b64(b []byte) string {
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// b64Ptr nil 表示没有值，写入日志时省略该字段
+func b64Ptr(b []byte) *string {
+	if b == nil {
+		return nil
+	}
+	s := b64(b)
+	return &s
+}
+
+// auditBatch 一个事务中的修改。日志先于数据：提交之前 write 把每个修改连同原值写入审计日志并落盘，
+// 写不进去时不提交；提交成功后 committed 写一条 commit 记录。
+// 进程在两者之间退出或提交失败时，日志中会留下没有 commit 记录的修改，undo 不恢复这些修改
+type auditBatch struct {
+	c       *TiKVClient
+	id      uint64
+	records []*utils.AuditRecord
+}
+
+func (c *TiKVClient) newAuditBatch() *auditBatch {
+	return &auditBatch{c: c}
+}
+
+// reset 清空记录，事务重做时在每次执行开始调用
+func (b *auditBatch) reset() {
+	b.records = b.records[:0]
+}
+
+func (b *auditBatch) deleted(key, value []byte) {
+	b.records = append(b.records, &utils.AuditRecord{Action: actionDelete, Key: b64(key), Before: b64Ptr(value)})
+}
+
+// set old 为 nil 表示 key 原来不存在
+func (b *auditBatch) set(key, old, value []byte) {
+	b.records = append(b.records, &utils.AuditRecord{Action: actionSet, Key: b64(key), Before: b64Ptr(old), After: b64Ptr(value)})
+}
+
+// write 在事务提交之前调用，返回错误时调用方回滚事务。可以在多个 goroutine 中调用
+func (b *auditBatch) write() error {
+	if b.c.noLog || len(b.records) == 0 {
+		return nil
+	}
+	b.id = atomic.AddUint64(&b.c.batchSeq, 1)
+	for _, r := range b.records {
+		r.Batch, r.Result = b.id, "pending"
+		if err := b.c.writeLog(r); err != nil {
+			return fmt.Errorf("write audit log err: %v, the batch was not committed", err)
+		}
+	}
+	if err := b.c.syncLog(); err != nil {
+		return fmt.Errorf("sync audit log err: %v, the batch was not committed", err)
+	}
+	return nil
+}
+
+// syncLog 把已写入的审计记录落盘，当前命令带 -nolog 时什么都不做
+func (c *TiKVClient) syncLog() error {
+	if c.noLog {
+		return nil
+	}
+	return base.AuditLog.Sync()
+}
+
+// committed 事务提交成功后在命令的 goroutine 中调用，写入失败时在命令结束时报告
+func (b *auditBatch) committed(commitTS uint64) {
+	if b.c.noLog || len(b.records) == 0 {
+		return
+	}
+	b.c.opLogged += len(b.records)
+	err := b.c.writeLog(&utils.AuditRecord{Action: actionCommit, Batch: b.id, CommitTS: commitTS, Result: "ok"})
+	if err != nil && b.c.logErr == nil {
+		b.c.logErr = err
+	}
+}
+
+// resultText 命令结果在审计日志中的写法
+func resultText(status int) string {
+	switch status {
+	case ExitOK:
+		return "ok"
+	case ExitNotFound:
+		return "not found"
+	case ExitCancelled:
+		return "cancelled"
+	}
+	return "error"
+}
+
+// handleAuditVerify 按顺序校验审计日志文件的 hash 链，未给出文件时校验日志目录下的全部文件。
+// 轮转出的文件和正在写入的文件连成一条链，前一个文件的最后一条与后一个文件的第一条也要对上；
+// 被保留策略清理掉的文件不影响剩下的文件的校验
+func (c *TiKVClient) handleAuditVerify(files []string) {
+	if len(files) == 0 {
+		var err error
+		if files, err = utils.AuditFiles(base.LogDir); err != nil {
+			fmt.Printf("list log files err: %v\n", err)
+			c.status = ExitError
+			return
+		}
+		if len(files) == 0 {
+			fmt.Printf("no audit log under %s\n", base.LogDir)
+			c.status = ExitNotFound
+			return
+		}
+	}
+	bad := 0
+	prev := ""
+	for i, f := range files {
+		if i == 0 || !utils.AuditChained(files[i-1], f) {
+			prev = ""
+		}
+		last, n, err := utils.VerifyAudit(f, prev)
+		if err != nil {
+			fmt.Printf("FAILED  %s: %v\n", f, err)
+			bad++
+			prev = ""
+			continue
+		}
+		prev = last
+		fmt.Printf("ok      %s: %d records\n", f, n)
+	}
+	if bad > 0 {
+		fmt.Printf("%d of %d files failed verification\n", bad, len(files))
+		c.status = ExitError
+	}
+}
@@ -0,0 +1,773 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/tikv/client-go/v2/oracle"
+	"strconv"
+	"strings"
+	"tikv/utils"
+	"time"
+)
+
+var (
+	limitFlag      = flagSpec{name: "limit", kind: intFlag, value: "n", usage: "stop after n keys"}
+	pvFlag         = flagSpec{name: "pv", kind: boolFlag, usage: "print values as well as keys"}
+	valueFlag      = flagSpec{name: "value", kind: stringFlag, value: "xxx", usage: "match values containing xxx (case sensitive unless -icase)"}
+	matchFlag      = flagSpec{name: "match", kind: stringFlag, value: "regex", usage: "match values against a regular expression"}
+	keyMatchFlag   = flagSpec{name: "key-match", kind: stringFlag, value: "regex", usage: "match keys against a regular expression"}
+	icaseFlag      = flagSpec{name: "icase", kind: boolFlag, usage: "make -value, -match and -key-match case insensitive"}
+	whereFlag      = flagSpec{name: "where", kind: stringFlag, value: "expr", usage: "match JSON values by expression, e.g. '.owner == \"C003\" && .lockTime < now() - 72h'"}
+	nologFlag      = flagSpec{name: "nolog", kind: boolFlag, usage: "do not write this command's changes to the audit log", noDefault: true}
+	outFlag        = flagSpec{name: "o", kind: stringFlag, value: "format", choices: outputFormats, usage: "output format: table|json|jsonl|csv|raw"}
+	afterFlag      = flagSpec{name: "after", kind: keyFlag, value: "key", usage: "start right after this key (before it with -reverse)"}
+	fromFlag       = flagSpec{name: "from", kind: keyFlag, value: "key", usage: "start at this key, inclusive"}
+	parallelFlag   = flagSpec{name: "parallel", kind: intFlag, value: "n", usage: "scan up to n regions concurrently (default 4)"}
+	atFlag         = flagSpec{name: "at", kind: stringFlag, value: "\"2006-01-02 15:04:05\"", usage: "read the data as it was at this time (profile timezone)"}
+	atTSFlag       = flagSpec{name: "at-ts", kind: intFlag, value: "tso", usage: "read the data as it was at this TSO"}
+	reverseFlag    = flagSpec{name: "reverse", kind: boolFlag, usage: "scan from the end of the range backwards, newest TSO keys first"}
+	fileFlag       = flagSpec{name: "file", kind: stringFlag, value: "path", usage: "read keys from a file, one per line, written like command arguments"}
+	kfmtFlag       = flagSpec{name: "kfmt", kind: stringFlag, value: "fmt", choices: utils.ByteFormats, usage: "show keys as auto|hex|escape|utf8 (auto escapes non-printable bytes)"}
+	vfmtFlag       = flagSpec{name: "vfmt", kind: stringFlag, value: "fmt", choices: utils.ByteFormats, usage: "show values as auto|hex|escape|utf8"}
+	formatFlag     = flagSpec{name: "format", kind: stringFlag, value: "fmt", choices: dumpFormats, usage: "file format: jsonl|csv (default: csv for .csv and .csv.gz, otherwise jsonl)"}
+	checkpointFlag = flagSpec{name: "checkpoint", kind: stringFlag, value: "path", usage: "checkpoint file (default <file>.ckpt)"}
+	resumeFlag     = flagSpec{name: "resume", kind: boolFlag, usage: "continue an interrupted run from its checkpoint"}
+	forceFlag      = flagSpec{name: "force", kind: boolFlag, usage: "also restore keys that were re-created or changed after the op", noDefault: true}
+	dryRunFlag     = flagSpec{name: "dry-run", kind: boolFlag, usage: "only report the range, count and size of the keys that would be deleted"}
+	rateFlag       = flagSpec{name: "rate", kind: intFlag, value: "n", usage: "delete at most n keys per second"}
+	bytesRateFlag  = flagSpec{name: "bytes-rate", kind: stringFlag, value: "size", usage: "delete at most this many key and value bytes per second, e.g. 8MB"}
+	pauseFlag      = flagSpec{name: "pause", kind: stringFlag, value: "duration", usage: "sleep between batches, e.g. 200ms"}
+	showFlag       = flagSpec{name: "show", kind: intFlag, value: "n", usage: "with -dry-run, list the first and last n keys (default 10)"}
+)
+
+// 参数中的 key 和 value 支持以下写法
+const literalHelp = `keys and values may be written as x'4f53' (hex), b64:T1M= (base64) or "OS\x00" (inside double quotes only \", \\ and \xNN are escapes)`
+
+func init() {
+	register(
+		&command{
+			name: "get",
+			synopsis: []string{
+				"get <key> [-at=time|-at-ts=tso] [-o=format] [-kfmt=fmt] [-vfmt=fmt]",
+				"get -file=keys.txt [-at=time|-at-ts=tso] [-o=format] [-kfmt=fmt] [-vfmt=fmt]",
+			},
+			summary: "read the value of a key, or of every key listed in a file; " + literalHelp,
+			args:    []argSpec{{name: "key", optional: true}},
+			flags:   []flagSpec{fileFlag, atFlag, atTSFlag, outFlag, kfmtFlag, vfmtFlag},
+			examples: []string{
+				"get OS/T03/Data/Lock/4657061437112320001000000",
+				"get OS/T03/config -o json",
+				`get "OS/T03/\x00meta" -vfmt=hex`,
+				`get OS/T03/config -at="2025-07-01 10:00:00"`,
+				"get -file=incident-keys.txt -o csv",
+			},
+			run: func(c *TiKVClient, in *input) {
+				if in.has("file") {
+					runMultiGet(c, in)
+					return
+				}
+				if len(in.args) == 0 {
+					c.usage(in.cmd.usageLine())
+					return
+				}
+				keys, ok := c.keyArgs(in, 1)
+				if !ok {
+					return
+				}
+				ts, ok := c.readTS(in)
+				if !ok {
+					return
+				}
+				p := c.newPrinter(in, true)
+				p.single, p.status = true, true
+				c.handleGet(keys[0], ts, p)
+			},
+		},
+		&command{
+			name:     "mget",
+			synopsis: []string{"mget <key>... [-file=keys.txt] [-at=time|-at-ts=tso] [-o=format] [-kfmt=fmt] [-vfmt=fmt]"},
+			summary:  "read many keys in batches and show whether each one exists",
+			args:     []argSpec{{name: "key", optional: true, variadic: true}},
+			flags:    []flagSpec{fileFlag, atFlag, atTSFlag, outFlag, kfmtFlag, vfmtFlag},
+			examples: []string{
+				"mget OS/T03/config OS/T04/config",
+				"mget -file=incident-keys.txt -o jsonl",
+			},
+			run: runMultiGet,
+		},
+		&command{
+			name:     "ll",
+			synopsis: []string{"ll <prefixKey> [endKey] [-where=expr] [-limit=n] [-after=key|-from=key] [-reverse] [-parallel=n] [-at=time|-at-ts=tso] [-pv] [-o=format] [-kfmt=fmt] [-vfmt=fmt]"},
+			summary:  "list keys under a prefix or in [prefixKey, endKey]; with -limit, 'next' shows the next page",
+			args:     []argSpec{{name: "prefixKey"}, {name: "endKey", optional: true}},
+			flags:    []flagSpec{whereFlag, limitFlag, afterFlag, fromFlag, reverseFlag, parallelFlag, atFlag, atTSFlag, pvFlag, outFlag, kfmtFlag, vfmtFlag},
+			examples: []string{
+				"ll OS/T03/ -limit=10",
+				"ll OS/T03/Data/Lock/1 OS/T03/Data/Lock/2 -pv",
+				"ll OS/T03/Data/Lock/ -pv -o jsonl",
+				"ll OS/T03/Data/Lock/ -reverse -limit=10",
+				`ll OS/T03/config -pv -at="2025-07-01 10:00:00"`,
+				"ll OS/T03/Data/Lock/ -after=OS/T03/Data/Lock/4657061437112320001000000 -limit=100",
+				"ll x'4f532f' -kfmt=hex",
+				`ll OS/T03/Data/Lock/ -where='.maxDuration > 24h' -pv`,
+			},
+			run: func(c *TiKVClient, in *input) {
+				t, ok := c.scanArgs(in)
+				if !ok {
+					return
+				}
+				p := c.newPrinter(in, in.bool("pv"))
+				c.handleListRange(t, in.int("limit", -1), p)
+				c.saveCursor(in, p)
+			},
+		},
+		&command{
+			name:     "tail",
+			synopsis: []string{"tail <prefixKey> [endKey] [-n=20] [-value=xxx] [-match=regex] [-key-match=regex] [-icase] [-where=expr] [-at=time|-at-ts=tso] [-pv] [-o=format] [-kfmt=fmt] [-vfmt=fmt]"},
+			summary:  "show the last n matching keys of a prefix or range without scanning it from the start",
+			args:     []argSpec{{name: "prefixKey"}, {name: "endKey", optional: true}},
+			flags: []flagSpec{
+				{name: "n", kind: intFlag, value: "n", usage: "number of keys to show (default 20)"},
+				valueFlag, matchFlag, keyMatchFlag, icaseFlag, whereFlag, atFlag, atTSFlag, pvFlag, outFlag, kfmtFlag, vfmtFlag,
+			},
+			examples: []string{
+				"tail OS/T03/Data/Lock/ -n=20",
+				"tail OS/T03/Data/Lock/ -n=5 -value=C003 -pv",
+				`tail OS/T03/Data/Lock/ -where='.owner == "C003"' -pv`,
+				`tail OS/T03/ -key-match='/Lock/\d+$'`,
+			},
+			run: func(c *TiKVClient, in *input) {
+				n := in.int("n", 20)
+				if n <= 0 {
+					c.usage("-n must be a positive number")
+					return
+				}
+				t, ok := c.scanArgs(in)
+				if !ok {
+					return
+				}
+				c.handleTail(t, n, c.newPrinter(in, in.bool("pv")))
+			},
+		},
+		&command{
+			name:     "next",
+			synopsis: []string{"next"},
+			summary:  "show the next page of the last ll/find that stopped at -limit (or just press Enter)",
+			run: func(c *TiKVClient, in *input) {
+				c.handleNext()
+			},
+		},
+		&command{
+			name:     "set",
+			synopsis: []string{"set <key> <value>", "set <key> <<EOF"},
+			summary:  "write a key; the value is the rest of the line, stored exactly as typed (including a leading -) unless it is a single x'..', b64: or \"...\" literal",
+			args:     []argSpec{{name: "key"}, {name: "value", rest: true}},
+			examples: []string{
+				`set OS/T03/config {"owner":"C003",  "maxDuration":259200000}`,
+				`set "key with spaces" 'value'`,
+				"set OS/T03/config <<EOF",
+				`set "OS/T03/\x00meta" x'00ff10'`,
+				"set OS/T03/offset -1",
+			},
+			run: func(c *TiKVClient, in *input) {
+				kv, ok := c.keyArgs(in, 2)
+				if !ok {
+					return
+				}
+				c.HandleSet(kv[0], kv[1])
+			},
+			writes: true,
+		},
+		&command{
+			name: "del",
+			synopsis: []string{
+				"del <key> [-dry-run] [-nolog] [-kfmt=fmt] [-vfmt=fmt]",
+				"del <startKey> <endKey> [-dry-run [-show=n]] [-parallel=n] [-checkpoint=path|-resume=checkpoint] [-rate=n] [-bytes-rate=size] [-pause=duration] [-nolog] [-kfmt=fmt] [-vfmt=fmt]",
+				"del <lockKey> <owner> <maxDuration> <lockTime> [-dry-run [-show=n]] [-rate=n] [-bytes-rate=size] [-pause=duration] [-nolog] [-kfmt=fmt] [-vfmt=fmt]",
+			},
+			summary: "delete a key, a key range, or matching lock records; range deletes run per region in parallel and can be resumed from their checkpoint",
+			args: []argSpec{
+				{name: "key"},
+				{name: "endKey|owner", optional: true},
+				{name: "maxDuration", optional: true},
+				{name: "lockTime", optional: true},
+			},
+			flags: []flagSpec{
+				dryRunFlag, showFlag, parallelFlag,
+				{name: "checkpoint", kind: stringFlag, value: "path", usage: "checkpoint file of a range delete (default del-<op>.ckpt in the log directory)", noDefault: true},
+				{name: "resume", kind: stringFlag, value: "checkpoint", usage: "continue an interrupted range delete from its checkpoint", noDefault: true},
+				rateFlag, bytesRateFlag, pauseFlag, nologFlag, kfmtFlag, vfmtFlag,
+			},
+			examples: []string{
+				"del OS/T03/config",
+				`del "OS/T03/\x00meta" -kfmt=hex`,
+				"del OS/T03/Data/Lock/2025-07-01-10:00:00 OS/T03/Data/Lock/2025-07-01-11:00:00",
+				"del OS/T03/Data/Lock/2025-07-01-10:00:00 OS/T03/Data/Lock/2025-07-01-11:00:00 -dry-run -show=5",
+				"del OS/T03/Data/Lock/2025-07-01-10:00:00 OS/T03/Data/Lock/2025-07-01-11:00:00 -rate=2000 -pause=100ms",
+				"del OS/T03/Data/Lock/ OS/T03/Data/Lock0 -parallel=8",
+				"del OS/T03/Data/Lock/ OS/T03/Data/Lock0 -resume=del-20250701-100000-3fa1.ckpt",
+				"del OS/T03 C003 259200000 1747729163004 -nolog",
+				"del OS/T03 C003 259200000 1747729163004 -dry-run",
+			},
+			run:    runDel,
+			writes: true,
+		},
+		&command{
+			name:     "find",
+			synopsis: []string{"find <prefixKey|pattern> [endKey] [-value=xxx] [-match=regex] [-key-match=regex] [-icase] [-where=expr] [-limit=n] [-after=key|-from=key] [-reverse] [-parallel=n] [-at=time|-at-ts=tso] [-pv] [-o=format] [-kfmt=fmt] [-vfmt=fmt]"},
+			summary:  "list keys matching the filters (at least one is required); with -limit, 'next' shows the next page",
+			args:     []argSpec{{name: "prefixKey"}, {name: "endKey", optional: true}},
+			flags:    []flagSpec{valueFlag, matchFlag, keyMatchFlag, icaseFlag, whereFlag, limitFlag, afterFlag, fromFlag, reverseFlag, parallelFlag, atFlag, atTSFlag, pvFlag, outFlag, kfmtFlag, vfmtFlag},
+			examples: []string{
+				`find OS/T03/Data/Lock/ -value=C003 -limit=20 -pv`,
+				`find OS/T03/ -value=C003 -pv -o csv`,
+				`find OS/T03/Data/Lock/ -match='"owner":"C00[37]"' -pv`,
+				`find OS/*/Data/Lock/* -value=c003 -icase`,
+				`find OS/T03/ -key-match='/config$'`,
+				`find OS/T03/Data/Lock/ -where='.owner == "C003" && .lockTime < now() - 72h' -pv`,
+			},
+			run: func(c *TiKVClient, in *input) {
+				t, ok := c.scanArgs(in)
+				if !ok {
+					return
+				}
+				if t.filter == nil {
+					c.usage(in.cmd.usageLine())
+					return
+				}
+				p := c.newPrinter(in, in.bool("pv"))
+				c.findLike(t, in.int("limit", -1), p)
+				c.saveCursor(in, p)
+			},
+		},
+		&command{
+			name:     "count",
+			synopsis: []string{"count <prefixKey|pattern> [endKey] [-value=xxx] [-match=regex] [-key-match=regex] [-icase] [-where=expr] [-parallel=n] [-at=time|-at-ts=tso] [-o=format]"},
+			summary:  "count keys, optionally only those matching the filters",
+			args:     []argSpec{{name: "prefixKey"}, {name: "endKey", optional: true}},
+			flags:    []flagSpec{valueFlag, matchFlag, keyMatchFlag, icaseFlag, whereFlag, parallelFlag, atFlag, atTSFlag, outFlag},
+			examples: []string{"count OS/T03/", "count OS/T03/Data/Lock/ -value=C003 -o json", "count OS/ -parallel=16", "count OS/*/Data/Lock/*", `count OS/T03/Data/Lock/ -where='.lockTime + .maxDuration < now()'`},
+			run: func(c *TiKVClient, in *input) {
+				t, ok := c.scanArgs(in)
+				if !ok {
+					return
+				}
+				c.handleCount(t, c.newPrinter(in, false))
+			},
+		},
+		&command{
+			name:     "fd",
+			synopsis: []string{"fd <prefixKey|pattern> [endKey] [-value=xxx] [-match=regex] [-key-match=regex] [-icase] [-where=expr] [-limit=n] [-parallel=n] [-dry-run [-show=n]] [-rate=n] [-bytes-rate=size] [-pause=duration] [-nolog] [-kfmt=fmt] [-vfmt=fmt]"},
+			summary:  "delete keys matching the filters (at least one is required)",
+			args:     []argSpec{{name: "prefixKey"}, {name: "endKey", optional: true}},
+			flags:    []flagSpec{valueFlag, matchFlag, keyMatchFlag, icaseFlag, whereFlag, limitFlag, parallelFlag, dryRunFlag, showFlag, rateFlag, bytesRateFlag, pauseFlag, nologFlag, kfmtFlag, vfmtFlag},
+			examples: []string{"fd OS/T03/Data/Lock/ -value=C003 -limit=100", "fd OS/T03/Data/Lock/ -value=C003 -dry-run", "fd OS/T03/Data/Lock/ -value=C003 -bytes-rate=4MB", `fd OS/*/Data/Lock/* -match='"owner":"C003"'`, `fd OS/T03/Data/Lock/ -where='.lockTime < time("2025-07-01 00:00:00")'`},
+			run: func(c *TiKVClient, in *input) {
+				t, ok := c.scanArgs(in)
+				if !ok {
+					return
+				}
+				if t.filter == nil {
+					c.usage(in.cmd.usageLine())
+					return
+				}
+				if in.bool("dry-run") {
+					c.previewDelete(t, in.int("limit", -1), in.int("show", defaultPreviewShown), nil)
+					return
+				}
+				th, err := newThrottle(in)
+				if err != nil {
+					c.usage(err.Error())
+					return
+				}
+				c.handleFindDelete(t, in.int("limit", -1), th)
+			},
+			writes: true,
+		},
+		&command{
+			name: "locks",
+			synopsis: []string{
+				"locks expired <tenant> [-at=time] [-owner=id] [-object=prefix] [-older=duration] [-limit=n] [-parallel=n] [-o=format] [-kfmt=fmt] [-vfmt=fmt]",
+				"locks reap <tenant> [-owner=id] [-object=prefix] [-older=duration] [-limit=n] [-parallel=n] [-dry-run [-show=n]] [-rate=n] [-bytes-rate=size] [-pause=duration] [-nolog] [-kfmt=fmt] [-vfmt=fmt]",
+				"locks stats <tenant> [-at=time] [-owner=id] [-object=prefix] [-older=duration] [-parallel=n] [-o=table|json] [-kfmt=fmt]",
+			},
+			summary: "list or delete the expired lock records under <tenant>/Data/Lock, i.e. lockTime + maxDuration before now, or summarize them per owner and maxDuration; malformed records are skipped and reported",
+			args:    []argSpec{{name: "expired|reap|stats"}, {name: "tenant"}},
+			flags: []flagSpec{
+				{name: "at", kind: stringFlag, value: "\"2006-01-02 15:04:05\"", usage: "with expired and stats, check expiry as of this time instead of now or the pinned snapshot (profile timezone)", noDefault: true},
+				{name: "owner", kind: stringFlag, value: "id", usage: "only locks held by this owner"},
+				{name: "object", kind: stringFlag, value: "prefix", usage: "only locks whose objectKey starts with prefix"},
+				{name: "older", kind: stringFlag, value: "duration", usage: "only locks taken at least this long ago, e.g. 72h or 7d"},
+				limitFlag, parallelFlag, dryRunFlag, showFlag, rateFlag, bytesRateFlag, pauseFlag, nologFlag, outFlag, kfmtFlag, vfmtFlag,
+			},
+			examples: []string{
+				"locks expired OS/T03",
+				"locks expired OS/T03 -owner=C003 -o json",
+				`locks expired OS/T03 -at="2025-07-08 00:00:00"`,
+				"locks reap OS/T03 -dry-run",
+				"locks reap OS/T03 -older=30d -object=prefix/ -rate=1000",
+				"locks stats OS/T03",
+				"locks stats OS/T03 -o json",
+			},
+			subFlags: map[string][]string{
+				"expired": {"at", "owner", "object", "older", "limit", "parallel", "o", "kfmt", "vfmt"},
+				"reap":    {"owner", "object", "older", "limit", "parallel", "dry-run", "show", "rate", "bytes-rate", "pause", "nolog", "kfmt", "vfmt"},
+				"stats":   {"at", "owner", "object", "older", "parallel", "o", "kfmt"},
+			},
+			run:      runLocks,
+			writes:   true,
+			readOnly: []string{"expired", "stats"},
+		},
+		&command{
+			name:     "export",
+			synopsis: []string{"export <prefixKey|pattern> [endKey] -o=file [-format=jsonl|csv] [-encoding=auto|base64] [-value=xxx] [-match=regex] [-key-match=regex] [-icase] [-where=expr] [-parallel=n] [-at=time|-at-ts=tso] [-checkpoint=path] [-resume]"},
+			summary:  "write the keys and values of a prefix or range to a JSONL or CSV file, .gz compresses; -resume continues an interrupted export",
+			args:     []argSpec{{name: "prefixKey"}, {name: "endKey", optional: true}},
+			flags: []flagSpec{
+				{name: "o", kind: stringFlag, value: "file", usage: "output file", noDefault: true},
+				formatFlag,
+				{name: "encoding", kind: stringFlag, value: "enc", choices: dumpEncodings, usage: "auto writes text as is and binary as b64:..., base64 encodes every key and value"},
+				valueFlag, matchFlag, keyMatchFlag, icaseFlag, whereFlag, parallelFlag, atFlag, atTSFlag, checkpointFlag, resumeFlag,
+			},
+			examples: []string{
+				"export OS/T03/ -o dump.jsonl.gz",
+				"export OS/T03/Data/Lock/1 OS/T03/Data/Lock/5 -o locks.csv",
+				`export OS/*/Data/Lock/* -where='.owner == "C003"' -o c003.jsonl`,
+				"export OS/ -o all.jsonl.gz -encoding=base64 -at=\"2025-07-01 10:00:00\"",
+				"export OS/T03/ -o dump.jsonl.gz -resume",
+			},
+			run: runExport,
+		},
+		&command{
+			name:     "import",
+			synopsis: []string{"import <file> [-format=jsonl|csv] [-on-conflict=overwrite|skip|fail|only-if-absent] [-dry-run] [-nolog]"},
+			summary:  "write the records of an export file back in batched transactions",
+			args:     []argSpec{{name: "file"}},
+			flags: []flagSpec{
+				formatFlag,
+				{name: "on-conflict", kind: stringFlag, value: "policy", choices: conflictPolicies, usage: "when a key exists with another value: overwrite (default), skip, fail before writing anything, or only-if-absent to leave every existing key alone"},
+				{name: "dry-run", kind: boolFlag, usage: "only report what would be written"},
+				nologFlag,
+			},
+			examples: []string{
+				"import dump.jsonl.gz -dry-run",
+				"import dump.jsonl.gz -on-conflict=skip",
+				"import locks.csv -on-conflict=fail",
+			},
+			run: func(c *TiKVClient, in *input) {
+				policy := in.str("on-conflict")
+				if policy == "" {
+					policy = conflictOverwrite
+				}
+				o := importOptions{format: in.str("format"), policy: policy, dryRun: in.bool("dry-run")}
+				c.handleImport(in.arg(0), o)
+			},
+			writes: true,
+		},
+		&command{
+			name:     "undo",
+			synopsis: []string{"undo <op-id> [-force]"},
+			summary:  "restore the keys deleted or overwritten by an earlier command, found by its op id in the log directory",
+			args:     []argSpec{{name: "op-id"}},
+			flags:    []flagSpec{forceFlag},
+			examples: []string{"undo 20250701-100000-3fa1", "undo 20250701-100000-3fa1 -force"},
+			run: func(c *TiKVClient, in *input) {
+				c.handleUndo(in.arg(0), in.bool("force"))
+			},
+			writes: true,
+		},
+		&command{
+			name:     "restore",
+			synopsis: []string{"restore -log=file -op=id [-force]"},
+			summary:  "like undo, but read the op from the given log file",
+			flags: []flagSpec{
+				{name: "log", kind: stringFlag, value: "file", usage: "audit log file written by a delete or set, .gz files are read as is"},
+				{name: "op", kind: stringFlag, value: "id", usage: "op id printed by the command"},
+				forceFlag,
+			},
+			examples: []string{"restore -log=/var/log/tikvcli/tikvcli-audit-2025-07-02T00-00-00.000.log.gz -op=20250701-100000-3fa1"},
+			run: func(c *TiKVClient, in *input) {
+				if in.str("log") == "" || in.str("op") == "" {
+					c.usage(in.cmd.usageLine())
+					return
+				}
+				c.handleRestore(in.str("log"), in.str("op"), in.bool("force"))
+			},
+			writes: true,
+		},
+		&command{
+			name:     "audit",
+			synopsis: []string{"audit verify [file...]"},
+			summary:  "check the hash chain of the audit logs, by default every log in the log directory",
+			args:     []argSpec{{name: "verify"}, {name: "file", optional: true, variadic: true}},
+			examples: []string{"audit verify", "audit verify /var/log/tikvcli/tikvcli-audit.log"},
+			run: func(c *TiKVClient, in *input) {
+				if in.arg(0) != "verify" {
+					c.usage(in.cmd.usageLine())
+					return
+				}
+				c.handleAuditVerify(in.args[1:])
+			},
+		},
+		&command{
+			name:     "purge",
+			synopsis: []string{"purge <prefix> [-parallel=n] [-allow-protected] [-confirm=prefix]"},
+			summary:  "admin only: delete every key under a prefix with all versions via DeleteRange, much faster than del but cannot be undone",
+			args:     []argSpec{{name: "prefix"}},
+			flags: []flagSpec{
+				{name: "parallel", kind: intFlag, value: "n", usage: "delete up to n regions concurrently (default 4)"},
+				{name: "allow-protected", kind: boolFlag, usage: "purge even if the range overlaps the profile's protected-prefixes", noDefault: true},
+				{name: "confirm", kind: keyFlag, value: "prefix", usage: "the prefix again, instead of typing it at the prompt", noDefault: true},
+			},
+			examples: []string{"purge OS/T05/", "purge OS/T05/ -parallel=16 -confirm=OS/T05/"},
+			run: func(c *TiKVClient, in *input) {
+				prefix, err := in.bytes(0)
+				if err != nil {
+					fmt.Println(err)
+					c.status = ExitError
+					return
+				}
+				parallel := in.int("parallel", defaultParallel)
+				if parallel < 1 {
+					c.usage("-parallel must be at least 1")
+					return
+				}
+				o := purgeOptions{parallel: parallel, allowProtected: in.bool("allow-protected")}
+				if in.has("confirm") {
+					o.confirm = in.key("confirm")
+					if o.confirm == nil {
+						o.confirm = []byte{}
+					}
+				}
+				c.handlePurge(prefix, o)
+			},
+			writes: true,
+		},
+		&command{
+			name:     "version",
+			synopsis: []string{"version"},
+			summary:  "print the tool version",
+			run: func(c *TiKVClient, in *input) {
+				c.handleVersion()
+			},
+		},
+		&command{
+			name:     "help",
+			synopsis: []string{"help [command]"},
+			summary:  "list commands or show the usage of one command",
+			args:     []argSpec{{name: "command", optional: true}},
+			examples: []string{"help", "help find"},
+			run: func(c *TiKVClient, in *input) {
+				if !printHelp(in.arg(0)) {
+					c.status = ExitError
+				}
+			},
+		},
+		&command{
+			name:     "snapshot",
+			synopsis: []string{"snapshot [time|tso|off]"},
+			summary:  "pin every later read to a point in time until 'snapshot off'; commands that change data (fd, del, locks reap) and their -dry-run still read the latest data",
+			args:     []argSpec{{name: "time|tso|off", optional: true, rest: true}},
+			examples: []string{"snapshot 2025-07-01 10:00:00", "snapshot 459038124316229633", "snapshot off"},
+			run: func(c *TiKVClient, in *input) {
+				c.handleSnapshot(in.arg(0))
+			},
+		},
+		&command{
+			name:     "connect",
+			synopsis: []string{"connect <profile|pdAddr1,pdAddr2,...>"},
+			summary:  "switch to another cluster without restarting",
+			args:     []argSpec{{name: "profile|endpoints"}},
+			examples: []string{"connect staging", "connect 10.0.11.33:2379,10.0.11.34:2379"},
+			run: func(c *TiKVClient, in *input) {
+				c.handleConnect(in.arg(0), false)
+			},
+		},
+		&command{
+			name:     "use",
+			synopsis: []string{"use [profile]"},
+			summary:  "switch to a profile from the config file, or list profiles",
+			args:     []argSpec{{name: "profile", optional: true}},
+			examples: []string{"use", "use dr"},
+			run: func(c *TiKVClient, in *input) {
+				if in.arg(0) == "" {
+					c.handleProfiles()
+					return
+				}
+				c.handleConnect(in.arg(0), true)
+			},
+		},
+		&command{
+			name:     "exit",
+			synopsis: []string{"exit"},
+			summary:  "leave the client",
+		},
+	)
+}
+
+// keyArgs 将前 n 个位置参数按字面量解析为字节，未给出的为 nil；解析失败时打印错误并返回 false
+func (c *TiKVClient) keyArgs(in *input, n int) ([][]byte, bool) {
+	keys := make([][]byte, n)
+	for i := range keys {
+		b, err := in.bytes(i)
+		if err != nil {
+			fmt.Println(err)
+			c.status = ExitError
+			return nil, false
+		}
+		keys[i] = b
+	}
+	return keys, true
+}
+
+// scanArgs 解析扫描类命令共有的参数：<prefixKey> [endKey]、-after/-from、-reverse、-parallel、
+// -at/-at-ts 以及各种过滤条件
+func (c *TiKVClient) scanArgs(in *input) (scanTask, bool) {
+	keys, ok := c.keyArgs(in, 2)
+	if !ok {
+		return scanTask{}, false
+	}
+	if in.has("after") && in.has("from") {
+		c.usage("use either -after or -from")
+		return scanTask{}, false
+	}
+	parallel := in.int("parallel", defaultParallel)
+	if parallel < 1 {
+		c.usage("-parallel must be at least 1")
+		return scanTask{}, false
+	}
+	ts, ok := c.readTS(in)
+	if !ok {
+		return scanTask{}, false
+	}
+	// 直接写出的前缀中带 * 时作为按段匹配的 glob，从第一个通配符之前的部分开始扫描
+	var glob string
+	if tok := in.raw[0]; !tok.Quoted && !strings.HasPrefix(tok.Raw, "b64:") {
+		var literal string
+		var err error
+		if glob, literal, err = splitGlob(string(keys[0])); err != nil {
+			c.usage(err.Error())
+			return scanTask{}, false
+		}
+		keys[0] = []byte(literal)
+	}
+	filter, err := newKeyFilter(in, glob)
+	if err != nil {
+		c.usage(err.Error())
+		return scanTask{}, false
+	}
+	reverse := in.bool("reverse")
+	start, end := scanBounds(in, keys[0], keys[1], reverse)
+	return scanTask{start: start, end: end, reverse: reverse, parallel: parallel, ts: ts, filter: filter}, true
+}
+
+// runMultiGet mget 以及 get -file：参数中的 key 在前，文件中的 key 在后
+func runMultiGet(c *TiKVClient, in *input) {
+	keys := make([][]byte, 0, len(in.args))
+	for i := range in.args {
+		key, err := in.bytes(i)
+		if err != nil {
+			fmt.Println(err)
+			c.status = ExitError
+			return
+		}
+		keys = append(keys, key)
+	}
+	if in.has("file") {
+		fileKeys, err := utils.ReadKeyList(in.str("file"))
+		if err != nil {
+			fmt.Printf("read key file err: %v\n", err)
+			c.status = ExitError
+			return
+		}
+		keys = append(keys, fileKeys...)
+	}
+	if len(keys) == 0 {
+		c.usage(in.cmd.usageLine())
+		return
+	}
+	ts, ok := c.readTS(in)
+	if !ok {
+		return
+	}
+	p := c.newPrinter(in, true)
+	p.status = true
+	c.handleMultiGet(keys, ts, p)
+}
+
+func runDel(c *TiKVClient, in *input) {
+	keys, ok := c.keyArgs(in, 2)
+	if !ok {
+		return
+	}
+	dryRun, show := in.bool("dry-run"), in.int("show", defaultPreviewShown)
+	th, err := newThrottle(in)
+	if err != nil {
+		c.usage(err.Error())
+		return
+	}
+	switch len(in.args) {
+	case 1:
+		if dryRun {
+			t := scanTask{start: keys[0], end: append(append([]byte{}, keys[0]...), 0), parallel: 1}
+			c.previewDelete(t, 0, show, nil)
+			return
+		}
+		if c.confirm(fmt.Sprintf("Are you sure to delete key=%s? (yes/no): ", c.fmtKey(keys[0]))) {
+			c.handleDelete(keys[0])
+		}
+	case 2:
+		if dryRun {
+			start, end := delRangeBounds(keys[0], keys[1])
+			c.previewDelete(scanTask{start: start, end: end, parallel: defaultParallel}, 0, show, nil)
+			return
+		}
+		if in.has("resume") && in.str("resume") == "" {
+			c.usage("-resume requires the checkpoint file")
+			return
+		}
+		parallel := in.int("parallel", defaultParallel)
+		if parallel < 1 {
+			c.usage("-parallel must be at least 1")
+			return
+		}
+		c.handleDelRange(keys[0], keys[1], delRangeOptions{
+			parallel:   parallel,
+			checkpoint: in.str("checkpoint"),
+			resume:     in.str("resume"),
+			cmd:        fmt.Sprintf("del %s %s", in.raw[0].Raw, in.raw[1].Raw),
+			th:         th,
+		})
+	case 4:
+		maxDuration, err1 := strconv.ParseInt(in.arg(2), 10, 64)
+		lockTime, err2 := strconv.ParseInt(in.arg(3), 10, 64)
+		if err1 != nil || err2 != nil {
+			fmt.Println("maxDuration and lockTime must be integers")
+			c.status = ExitError
+			return
+		}
+		if dryRun {
+			owner := in.arg(1)
+			start, end := lockRange(keys[0])
+			c.previewDelete(scanTask{start: start, end: end, parallel: defaultParallel}, 0, show, func(value []byte) (bool, error) {
+				return lockMatches(value, owner, maxDuration, lockTime)
+			})
+			return
+		}
+		c.handleDeleteLock(keys[0], in.arg(1), maxDuration, lockTime, th)
+	default:
+		c.usage(in.cmd.usageLine())
+	}
+}
+
+// runExport export：-o 必须给出，-resume 时从 checkpoint 继续
+func runExport(c *TiKVClient, in *input) {
+	if in.str("o") == "" {
+		c.usage(in.cmd.usageLine())
+		return
+	}
+	t, ok := c.scanArgs(in)
+	if !ok {
+		return
+	}
+	o := exportOptions{
+		file:       in.str("o"),
+		format:     dumpFormat(in.str("o"), in.str("format")),
+		encoding:   in.str("encoding"),
+		checkpoint: in.str("checkpoint"),
+		resume:     in.bool("resume"),
+	}
+	if o.encoding == "" {
+		o.encoding = encodingAuto
+	}
+	if o.checkpoint == "" {
+		o.checkpoint = o.file + ".ckpt"
+	}
+	c.handleExport(t, o)
+}
+
+// runLocks locks expired|reap|stats <tenant>
+func runLocks(c *TiKVClient, in *input) {
+	sub := in.arg(0)
+	if sub != "expired" && sub != "reap" && sub != "stats" {
+		c.usage(in.cmd.usageLine())
+		return
+	}
+	tenant, err := in.bytes(1)
+	if err != nil {
+		fmt.Println(err)
+		c.status = ExitError
+		return
+	}
+	if len(tenant) == 0 {
+		c.usage("locks needs a tenant, e.g. OS/T03")
+		return
+	}
+	parallel := in.int("parallel", defaultParallel)
+	if parallel < 1 {
+		c.usage("-parallel must be at least 1")
+		return
+	}
+	f := &lockFilter{at: time.Now().UnixMilli(), owner: in.str("owner"), object: in.str("object")}
+	if c.snapshotTS != 0 && sub != "reap" {
+		// 固定 snapshot 后 expired 和 stats 读该版本的锁，默认按该时间判断是否过期
+		f.at = oracle.ExtractPhysical(c.snapshotTS)
+	}
+	if in.has("at") {
+		t, err := utils.ParseTime(in.str("at"))
+		if err != nil {
+			c.usage(err.Error())
+			return
+		}
+		f.at = t.UnixMilli()
+	}
+	if s := in.str("older"); s != "" {
+		d, err := utils.ParseDuration(s)
+		if err != nil {
+			c.usage("-older: " + err.Error())
+			return
+		}
+		f.olderThan = d.Milliseconds()
+	}
+	start, end := lockRange(bytes.TrimSuffix(tenant, []byte("/")))
+	t := scanTask{start: start, end: end, parallel: parallel}
+	limit := in.int("limit", -1)
+	if sub != "reap" {
+		t.ts = c.snapshotTS
+	} else if c.snapshotTS != 0 {
+		fmt.Printf("note: locks reap reads the latest data, not the snapshot pinned to %s\n", utils.TikvTimeFormat(c.snapshotTS))
+	}
+
+	switch sub {
+	case "expired":
+		c.handleLocksExpired(t, f, limit, c.newPrinter(in, true))
+		return
+	case "stats":
+		format := c.outputFormat(in)
+		if format == formatJSONL {
+			format = formatJSON
+		}
+		if format != formatTable && format != formatJSON {
+			c.usage("locks stats supports -o table|json")
+			return
+		}
+		c.handleLockStats(c.fmtKey(bytes.TrimSuffix(tenant, []byte("/"))), t, f, format)
+		return
+	}
+	if in.bool("dry-run") {
+		c.previewDelete(t, limit, in.int("show", defaultPreviewShown), func(value []byte) (bool, error) {
+			d, err := parseLock(value)
+			if err != nil {
+				return false, err
+			}
+			return f.match(d), nil
+		})
+		return
+	}
+	th, err := newThrottle(in)
+	if err != nil {
+		c.usage(err.Error())
+		return
+	}
+	c.reapLocks(t, f.match, limit, th)
+}
@@ -0,0 +1,158 @@
+package actions
+
+import (
+	"fmt"
+	"github.com/tikv/client-go/v2/config"
+	"github.com/tikv/client-go/v2/txnkv"
+	"tikv/base"
+	"tikv/utils"
+)
+
+// Connect 按 profile 连接集群。配置了证书时先与第一个 PD 握手一次，
+// 以便在证书有问题时给出明确的原因
+func Connect(p *utils.Profile) (*txnkv.Client, error) {
+	if len(p.Endpoints) == 0 {
+		return nil, fmt.Errorf("no PD endpoints given")
+	}
+	if err := p.Security.Validate(); err != nil {
+		return nil, err
+	}
+
+	security := config.NewSecurity(p.Security.CA, p.Security.Cert, p.Security.Key, p.Security.VerifyCN)
+	if p.Security.Enabled() {
+		tlsConfig, err := security.ToTLSConfig()
+		if err != nil {
+			return nil, fmt.Errorf("load certificates err: %v", err)
+		}
+		if err := utils.CheckTLS(p.Endpoints[0], tlsConfig); err != nil {
+			return nil, err
+		}
+	}
+	// client-go 从全局配置读取证书，切换 profile 时需要一并替换
+	config.UpdateGlobal(func(conf *config.Config) {
+		conf.Security = security
+	})
+
+	client, err := txnkv.NewClient(p.Endpoints)
+	if err != nil && !p.Security.Enabled() && utils.SpeaksTLS(p.Endpoints[0]) {
+		return nil, fmt.Errorf("%v (%s expects TLS, set -ca/-cert/-key or the profile's security section)", err, p.Endpoints[0])
+	}
+	return client, err
+}
+
+// ApplyProfile 切换为 p 的时区，按 p 的日志配置重新打开审计日志。
+// 启动参数 -log-dir 优先于 profile 中的 log-dir
+func ApplyProfile(p *utils.Profile) {
+	if err := utils.SetTimezone(p.Timezone); err != nil {
+		fmt.Println(err)
+	}
+	dir := p.LogDir
+	if base.LogDirFlag != "" {
+		dir = base.LogDirFlag
+	}
+	if dir == "" {
+		dir = utils.DefaultLogDir()
+	}
+	base.AuditLog.Close()
+	base.LogDir = dir
+	var err error
+	if base.AuditLog, err = utils.InitLog(dir, p.Log); err != nil {
+		fmt.Printf("audit log err: %v\n", err)
+	}
+}
+
+// profileLabel 提示符和提示信息中显示的集群名称
+func profileLabel(p *utils.Profile) string {
+	if p == nil {
+		return ""
+	}
+	if p.Name != "" {
+		return p.Name
+	}
+	if len(p.Endpoints) > 0 {
+		return p.Endpoints[0]
+	}
+	return ""
+}
+
+// prompt 显示当前集群，固定了读取版本时一并显示
+func (c *TiKVClient) prompt() string {
+	label := profileLabel(c.Profile)
+	if c.snapshotTS != 0 {
+		label += "@" + utils.TikvTimeFormat(c.snapshotTS)
+	}
+	if label != "" {
+		return "TiKVClient[" + label + "]> "
+	}
+	return "TiKVClient> "
+}
+
+// defaults 当前 profile 中配置的选项默认值，启动参数 -o 优先于 profile 中的 o
+func (c *TiKVClient) defaults() map[string]string {
+	defaults := map[string]string{}
+	if c.Profile != nil {
+		for k, v := range c.Profile.Defaults {
+			defaults[k] = v
+		}
+	}
+	if c.Output != "" {
+		defaults["o"] = c.Output
+	}
+	return defaults
+}
+
+// handleConnect 连接到 profile 或一组 PD 地址，成功后替换当前连接
+func (c *TiKVClient) handleConnect(target string, profileOnly bool) {
+	var p *utils.Profile
+	if c.Config != nil {
+		p = c.Config.Profiles[target]
+	}
+	if p == nil {
+		if profileOnly {
+			if c.Config == nil {
+				fmt.Printf("profile %s not found\n", target)
+			} else {
+				_, err := c.Config.Profile(target)
+				fmt.Println(err)
+			}
+			c.status = ExitError
+			return
+		}
+		p = &utils.Profile{Endpoints: utils.ParseEndpoints(target)}
+		if c.Profile != nil {
+			// 直接给出地址时沿用当前连接的证书
+			p.Security = c.Profile.Security
+		}
+	}
+
+	client, err := Connect(p)
+	if err != nil {
+		fmt.Printf("connect to %s err: %v\n", target, err)
+		c.status = ExitError
+		return
+	}
+	if c.Client != nil {
+		_ = c.Client.Close()
+	}
+	c.Client = client
+	c.Profile = p
+	c.cursor = nil
+	c.snapshotTS = 0
+	ApplyProfile(p)
+	fmt.Printf("connected to %s\n", profileLabel(p))
+}
+
+// handleProfiles 列出配置文件中的 profile，当前使用的以 * 标出
+func (c *TiKVClient) handleProfiles() {
+	if c.Config == nil || len(c.Config.Profiles) == 0 {
+		fmt.Printf("no profiles, add them to %s\n", utils.DefaultConfigPath())
+		return
+	}
+	for _, name := range c.Config.ProfileNames() {
+		mark := " "
+		if c.Profile != nil && c.Profile.Name == name {
+			mark = "*"
+		}
+		fmt.Printf("%s %-12s %v\n", mark, name, c.Config.Profiles[name].Endpoints)
+	}
+}
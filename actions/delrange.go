@@ -0,0 +1,325 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"tikv/base"
+	"tikv/utils"
+	"time"
+
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+const delCheckpointInterval = time.Second // 范围删除的 checkpoint 最多每秒写一次
+
+// delCheckpoint 范围删除的断点：范围按 region 切成的各段，以及每段删到的位置
+type delCheckpoint struct {
+	Start   string     `json:"start"`
+	End     string     `json:"end"`
+	Deleted int        `json:"deleted"`
+	Chunks  []delChunk `json:"chunks"`
+	Updated string     `json:"updated"`
+}
+
+// delChunk 一段 [Start, End)，Next 之前的 key 已删除，Done 表示整段已删完
+type delChunk struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+	Next  string `json:"next,omitempty"`
+	Done  bool   `json:"done,omitempty"`
+}
+
+type delRangeOptions struct {
+	parallel   int
+	checkpoint string // 新的删除写入的 checkpoint，默认在日志目录下
+	resume     string // 从这个 checkpoint 继续
+	cmd        string // 提示续传时的命令，不含选项
+	th         *throttle
+}
+
+// delBatch 一段中提交的一批，next 为下一批的起点，nil 表示这一段删完了
+type delBatch struct {
+	chunk    int
+	deleted  []kvPair
+	log      *auditBatch
+	commitTS uint64
+	next     []byte
+}
+
+// delRangeBounds del <startKey> <endKey> 删除的范围 [start, end)，包含 endKey 本身。
+// 两端都是 .../2006-01-02-15:04:05 形式的时间时换算为对应的 TSO key；endKey 全为 0xff 时 end 为 nil，不设上界
+func delRangeBounds(startArg, endArg []byte) (startKey, endKey []byte) {
+	start, end := string(startArg), string(endArg)
+	if strings.Contains(start, ":") && strings.Contains(end, ":") {
+		s1 := start[strings.LastIndex(start, "/")+1:]
+		s2 := end[strings.LastIndex(end, "/")+1:]
+		s1 = s1[:strings.LastIndex(s1, "-")] + " " + s1[strings.LastIndex(s1, "-")+1:]
+		s2 = s2[:strings.LastIndex(s2, "-")] + " " + s2[strings.LastIndex(s2, "-")+1:]
+		startTS := utils.TimeToTS(s1)
+		endTS := utils.TimeToTS(s2)
+		start = start[0:strings.LastIndex(start, "/")+1] + strconv.Itoa(int(startTS)) + "1000000"
+		end = end[0:strings.LastIndex(end, "/")+1] + strconv.Itoa(int(endTS)) + "1000001"
+	}
+	return []byte(start), utils.PrefixEnd([]byte(end))
+}
+
+func loadDelCheckpoint(path string) (*delCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ckpt := &delCheckpoint{}
+	if err := json.Unmarshal(data, ckpt); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return ckpt, nil
+}
+
+func (ckpt *delCheckpoint) save(path string) error {
+	ckpt.Updated = time.Now().Format(timeLayout)
+	return saveCheckpoint(path, ckpt)
+}
+
+// handleDelRange 按 region 把范围切成若干段，最多 o.parallel 段同时删除，每段按批提交。
+// 各段删到的位置记在 checkpoint 中，中断、出错或断开连接后用 -resume 继续
+func (c *TiKVClient) handleDelRange(startArg, endArg []byte, o delRangeOptions) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	start, end := delRangeBounds(startArg, endArg)
+	if !c.confirm("Are you sure to delete? (yes/no): ") {
+		return
+	}
+	startTime := time.Now()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	path := o.checkpoint
+	var ckpt *delCheckpoint
+	if o.resume != "" {
+		path = o.resume
+		var err error
+		if ckpt, err = loadDelCheckpoint(path); err != nil {
+			fmt.Printf("read checkpoint err: %v\n", err)
+			c.status = ExitError
+			return
+		}
+		if ckpt.Start != encodeField(start, encodingAuto) || ckpt.End != encodeField(end, encodingAuto) {
+			fmt.Printf("checkpoint %s belongs to another range: [%s, %s)\n", path, ckpt.Start, ckpt.End)
+			c.status = ExitError
+			return
+		}
+		fmt.Printf("resuming, %d keys already deleted\n", ckpt.Deleted)
+	} else {
+		ranges, err := c.splitByRegion(ctx, start, end)
+		if err != nil {
+			fmt.Printf("operation failed: %v\n", err)
+			c.status = ExitError
+			return
+		}
+		ckpt = &delCheckpoint{Start: encodeField(start, encodingAuto), End: encodeField(end, encodingAuto)}
+		for _, r := range ranges {
+			ckpt.Chunks = append(ckpt.Chunks, delChunk{Start: encodeField(r.start, encodingAuto), End: encodeField(r.end, encodingAuto)})
+		}
+		if path == "" {
+			path = filepath.Join(base.LogDir, "del-"+c.opID+".ckpt")
+		}
+	}
+
+	// 各段还没删的部分
+	var todo []int
+	from := make([][]byte, len(ckpt.Chunks))
+	to := make([][]byte, len(ckpt.Chunks))
+	for i, ch := range ckpt.Chunks {
+		if ch.Done {
+			continue
+		}
+		next := ch.Next
+		if next == "" {
+			next = ch.Start
+		}
+		var err1, err2 error
+		from[i], err1 = decodeField(next)
+		to[i], err2 = decodeField(ch.End)
+		if err1 != nil || err2 != nil {
+			fmt.Printf("read checkpoint err: chunk %d: invalid key\n", i)
+			c.status = ExitError
+			return
+		}
+		todo = append(todo, i)
+	}
+	if err := ckpt.save(path); err != nil {
+		fmt.Printf("write checkpoint err: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	fmt.Printf("checkpoint: %s, %d of %d chunks to delete\n", path, len(todo), len(ckpt.Chunks))
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	results := make(chan delBatch)
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for _, i := range todo {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < min(max(o.parallel, 1), max(len(todo), 1)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				if err := c.deleteChunk(ctx, i, from[i], to[i], o.th, results); err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// 删除前的值由各 goroutine 在提交之前写入日志，commit 记录、checkpoint 和进度都在当前 goroutine 中处理
+	deletedTotal := 0
+	lastSave := time.Now()
+	var saveErr error
+	for results != nil {
+		select {
+		case b, ok := <-results:
+			if !ok {
+				results = nil
+				continue
+			}
+			b.log.committed(b.commitTS)
+			ch := &ckpt.Chunks[b.chunk]
+			if b.next == nil {
+				ch.Done, ch.Next = true, ""
+			} else {
+				ch.Next = encodeField(b.next, encodingAuto)
+			}
+			ckpt.Deleted += len(b.deleted)
+			if len(b.deleted) > 0 {
+				deletedTotal += len(b.deleted)
+				fmt.Printf("Batch deleted: %d, Total deleted: %d%s\n", len(b.deleted), deletedTotal, o.th.progress())
+			}
+			if time.Since(lastSave) >= delCheckpointInterval {
+				if saveErr = ckpt.save(path); saveErr != nil {
+					cancel()
+				}
+				lastSave = time.Now()
+			}
+		case <-sigCh:
+			if c.status != ExitCancelled {
+				fmt.Println("\noperation cancelled, waiting for the running batches")
+				c.status = ExitCancelled
+				cancel()
+			}
+		}
+	}
+
+	done := true
+	for _, ch := range ckpt.Chunks {
+		done = done && ch.Done
+	}
+	if done && firstErr == nil && saveErr == nil {
+		_ = os.Remove(path)
+		c.status = ExitOK
+		fmt.Println("Total deleted:", deletedTotal, "time consuming:", time.Since(startTime))
+		o.th.printSummary()
+		return
+	}
+	if err := ckpt.save(path); err != nil {
+		fmt.Printf("write checkpoint err: %v\n", err)
+		saveErr = err
+	}
+	switch {
+	case firstErr != nil:
+		fmt.Printf("operation failed: %v\n", firstErr)
+		c.status = ExitError
+	case saveErr != nil:
+		c.status = ExitError
+	}
+	fmt.Printf("%d keys deleted before stopping, %d in total\n", deletedTotal, ckpt.Deleted)
+	if saveErr == nil {
+		fmt.Printf("continue with: %s -resume=%s\n", o.cmd, path)
+	}
+}
+
+// deleteChunk 从 from 开始按批删除 [from, end)，每批提交后交给 out，ctx 取消时在两批之间停止
+func (c *TiKVClient) deleteChunk(ctx context.Context, idx int, from, end []byte, th *throttle, out chan<- delBatch) error {
+	for from != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		b := delBatch{chunk: idx, log: c.newAuditBatch()}
+		err := th.execute(func() (*transaction.KVTxn, error) {
+			return c.beginWith(&b.commitTS)
+		}, func(txn *transaction.KVTxn) (int, int64, error) {
+			n, size, err := c.deleteBatch(txn, from, end, th.batchSize(deleteBatchSize), &b)
+			if err != nil {
+				return 0, 0, err
+			}
+			b.log.reset()
+			for _, kv := range b.deleted {
+				b.log.deleted(kv.key, kv.value)
+			}
+			return n, size, b.log.write()
+		})
+		if err != nil {
+			return err
+		}
+		out <- b
+		from = b.next
+	}
+	return nil
+}
+
+// deleteBatch 在 txn 中删除 [from, end) 的前 n 个 key，记在 b 中；还有剩余时 b.next 为下一个 key
+func (c *TiKVClient) deleteBatch(txn *transaction.KVTxn, from, end []byte, n int, b *delBatch) (int, int64, error) {
+	b.deleted, b.next = nil, nil
+	iter, err := txn.Iter(from, end)
+	if err != nil {
+		return 0, 0, fmt.Errorf("iter err: %v", err)
+	}
+	defer iter.Close()
+	var size int64
+	for iter.Valid() {
+		if len(b.deleted) == n {
+			b.next = bytes.Clone(iter.Key())
+			break
+		}
+		key, value := iter.Key(), iter.Value()
+		if err := txn.Delete(key); err != nil {
+			return 0, 0, fmt.Errorf("delete key=%s err: %v", c.fmtKey(key), err)
+		}
+		b.deleted = append(b.deleted, kvPair{key: key, value: value})
+		size += int64(len(key) + len(value))
+		if err := iter.Next(); err != nil {
+			return 0, 0, fmt.Errorf("iter.Next err: %v", err)
+		}
+	}
+	return len(b.deleted), size, nil
+}
@@ -0,0 +1,253 @@
+package actions
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+// export 文件格式，import 读取同样的格式
+const (
+	dumpJSONL = "jsonl"
+	dumpCSV   = "csv"
+)
+
+var dumpFormats = []string{dumpJSONL, dumpCSV}
+
+// key 和 value 的编码：auto 时可打印的 UTF-8 文本原样写出，其余写成 b64:<base64>；base64 时全部写成 b64:
+const (
+	encodingAuto   = "auto"
+	encodingBase64 = "base64"
+)
+
+var dumpEncodings = []string{encodingAuto, encodingBase64}
+
+const b64Prefix = "b64:"
+
+// dumpRecord jsonl 中的一行，csv 中的列为 key,value
+type dumpRecord struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// dumpFormat 未指定 -format 时按文件扩展名判断，.csv 与 .csv.gz 为 csv，其余为 jsonl
+func dumpFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	if strings.HasSuffix(strings.TrimSuffix(path, ".gz"), ".csv") {
+		return dumpCSV
+	}
+	return dumpJSONL
+}
+
+// encodeField 按 encoding 编码 key 或 value。本身以 b64: 开头的文本也编码，保证能原样读回
+func encodeField(b []byte, encoding string) string {
+	if encoding != encodingBase64 && utf8.Valid(b) && !strings.HasPrefix(string(b), b64Prefix) {
+		return string(b)
+	}
+	return b64Prefix + base64.StdEncoding.EncodeToString(b)
+}
+
+func decodeField(s string) ([]byte, error) {
+	if !strings.HasPrefix(s, b64Prefix) {
+		return []byte(s), nil
+	}
+	b, err := base64.StdEncoding.DecodeString(s[len(b64Prefix):])
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 %q: %v", s, err)
+	}
+	return b, nil
+}
+
+// dumpWriter 写 export 文件，路径以 .gz 结尾时 gzip 压缩。
+// sync 之后文件长度就是已写完的记录的边界，中断后从该位置截断继续写；
+// gzip 时每次 sync 结束当前 member，多个 member 拼接仍是合法的 gzip 文件
+type dumpWriter struct {
+	f      *os.File
+	gz     *gzip.Writer
+	w      *bufio.Writer
+	csv    *csv.Writer
+	format string
+	zip    bool
+}
+
+// newDumpWriter 创建文件；offset > 0 时打开已有文件并截断到 offset 继续写
+func newDumpWriter(path, format string, offset int64) (*dumpWriter, error) {
+	flag := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if offset > 0 {
+		flag = os.O_WRONLY
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if err := f.Truncate(offset); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	d := &dumpWriter{f: f, format: format, zip: strings.HasSuffix(path, ".gz")}
+	d.w = bufio.NewWriter(f)
+	if format == dumpCSV {
+		d.csv = csv.NewWriter(d.w)
+		if offset == 0 {
+			if err := d.write(dumpRecord{Key: "key", Value: "value"}); err != nil {
+				f.Close()
+				return nil, err
+			}
+		}
+	}
+	return d, nil
+}
+
+func (d *dumpWriter) write(r dumpRecord) error {
+	if d.zip && d.gz == nil {
+		// bufio 写入 gzip，gzip 写入文件
+		d.gz = gzip.NewWriter(d.f)
+		d.w.Reset(d.gz)
+	}
+	if d.csv != nil {
+		return d.csv.Write([]string{r.Key, r.Value})
+	}
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	_, err = d.w.Write(line)
+	return err
+}
+
+// sync 把已写的记录落盘，返回文件长度
+func (d *dumpWriter) sync() (int64, error) {
+	if d.csv != nil {
+		d.csv.Flush()
+		if err := d.csv.Error(); err != nil {
+			return 0, err
+		}
+	}
+	if err := d.w.Flush(); err != nil {
+		return 0, err
+	}
+	if d.gz != nil {
+		if err := d.gz.Close(); err != nil {
+			return 0, err
+		}
+		d.gz = nil
+	}
+	if err := d.f.Sync(); err != nil {
+		return 0, err
+	}
+	return d.f.Seek(0, io.SeekCurrent)
+}
+
+func (d *dumpWriter) close() error {
+	_, err := d.sync()
+	if cerr := d.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// dumpReader 读 export 写出的文件，.gz 自动解压
+type dumpReader struct {
+	f      *os.File
+	gz     *gzip.Reader
+	sc     *bufio.Scanner
+	csv    *csv.Reader
+	format string
+	line   int
+}
+
+func openDump(path, format string) (*dumpReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	d := &dumpReader{f: f, format: dumpFormat(path, format)}
+	var r io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		if d.gz, err = gzip.NewReader(f); err != nil {
+			f.Close()
+			return nil, err
+		}
+		r = d.gz
+	}
+	if d.format == dumpCSV {
+		d.csv = csv.NewReader(r)
+		d.csv.FieldsPerRecord = 2
+		header, err := d.csv.Read()
+		if err != nil && err != io.EOF {
+			d.close()
+			return nil, fmt.Errorf("read csv header: %v", err)
+		}
+		if err == nil && (header[0] != "key" || header[1] != "value") {
+			d.close()
+			return nil, fmt.Errorf("csv header must be key,value, got %s", strings.Join(header, ","))
+		}
+		d.line = 1
+	} else {
+		d.sc = bufio.NewScanner(r)
+		d.sc.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	}
+	return d, nil
+}
+
+// next 读下一条记录，读完时返回 io.EOF；jsonl 中的空行跳过
+func (d *dumpReader) next() (key, value []byte, err error) {
+	var r dumpRecord
+	if d.csv != nil {
+		fields, err := d.csv.Read()
+		if err != nil {
+			return nil, nil, err
+		}
+		d.line++
+		r = dumpRecord{Key: fields[0], Value: fields[1]}
+	} else {
+		for {
+			if !d.sc.Scan() {
+				if err := d.sc.Err(); err != nil {
+					return nil, nil, err
+				}
+				return nil, nil, io.EOF
+			}
+			d.line++
+			if strings.TrimSpace(d.sc.Text()) != "" {
+				break
+			}
+		}
+		if err := json.Unmarshal(d.sc.Bytes(), &r); err != nil {
+			return nil, nil, fmt.Errorf("line %d: %v", d.line, err)
+		}
+	}
+	if key, err = decodeField(r.Key); err == nil {
+		value, err = decodeField(r.Value)
+	}
+	if err == nil && len(key) == 0 {
+		err = fmt.Errorf("empty key")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("line %d: %v", d.line, err)
+	}
+	return key, value, nil
+}
+
+func (d *dumpReader) close() {
+	if d.gz != nil {
+		d.gz.Close()
+	}
+	d.f.Close()
+}
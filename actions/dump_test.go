@@ -0,0 +1,110 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeFieldRoundTrip(t *testing.T) {
+	tests := []struct {
+		data  string
+		plain bool // auto 时原样写出
+	}{
+		{"OS/T03/Data/Lock/a", true},
+		{`{"owner":"C003"}`, true},
+		{"中文", true},
+		{"", true},
+		{"OS\x00\xff", false},
+		{"\xff\xff", false},
+		{"b64:abc", false},
+	}
+	for _, tt := range tests {
+		for _, encoding := range dumpEncodings {
+			s := encodeField([]byte(tt.data), encoding)
+			if plain := s == tt.data; plain != (tt.plain && encoding == encodingAuto) {
+				t.Errorf("encodeField(%q, %s) = %q", tt.data, encoding, s)
+			}
+			got, err := decodeField(s)
+			if err != nil || !bytes.Equal(got, []byte(tt.data)) {
+				t.Errorf("decodeField(encodeField(%q, %s)) = %q, %v", tt.data, encoding, got, err)
+			}
+		}
+	}
+	if _, err := decodeField("b64:!!"); err == nil {
+		t.Error("decodeField should reject invalid base64")
+	}
+}
+
+// 中断时最后一次 sync 之后写了一半的内容被截掉，续写后文件仍可完整读回
+func TestDumpResumeAfterTruncate(t *testing.T) {
+	for _, name := range []string{"out.jsonl", "out.jsonl.gz", "out.csv", "out.csv.gz"} {
+		path := filepath.Join(t.TempDir(), name)
+		format := dumpFormat(path, "")
+		record := func(i int) dumpRecord {
+			return dumpRecord{Key: fmt.Sprintf("k%d", i), Value: encodeField([]byte{byte(i), 0xff}, encodingAuto)}
+		}
+
+		w, err := newDumpWriter(path, format, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 1; i <= 3; i++ {
+			if err := w.write(record(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		offset, err := w.sync()
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 模拟中断：写出未 sync 的记录后直接关闭文件，gzip member 没有结束
+		for i := 4; i <= 5; i++ {
+			w.write(record(i))
+		}
+		if w.csv != nil {
+			w.csv.Flush()
+		}
+		w.w.Flush()
+		w.f.Close()
+
+		w, err = newDumpWriter(path, format, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 4; i <= 6; i++ {
+			if err := w.write(record(i)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := openDump(path, "")
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var keys []string
+		for {
+			key, value, err := r.next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			i := len(keys) + 1
+			if !bytes.Equal(value, []byte{byte(i), 0xff}) {
+				t.Errorf("%s: value of %s = %q", name, key, value)
+			}
+			keys = append(keys, string(key))
+		}
+		r.close()
+		if fmt.Sprint(keys) != "[k1 k2 k3 k4 k5 k6]" {
+			t.Errorf("%s: keys = %v, want k1..k6", name, keys)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package actions
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// exportCheckpointRows 每导出这么多条落盘一次并更新 checkpoint
+const exportCheckpointRows = 10000
+
+// exportCheckpoint export 的断点：文件中已完整写入的记录到 LastKey 为止，长度为 Offset
+type exportCheckpoint struct {
+	File     string `json:"file"`
+	Format   string `json:"format"`
+	Encoding string `json:"encoding"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TS       uint64 `json:"ts,omitempty"` // 用 -at/-at-ts 或 snapshot 固定了版本时，续传沿用该版本
+	LastKey  string `json:"lastKey"`
+	Count    int    `json:"count"`
+	Offset   int64  `json:"offset"`
+	Updated  string `json:"updated"`
+}
+
+type exportOptions struct {
+	file       string
+	format     string
+	encoding   string
+	checkpoint string
+	resume     bool
+}
+
+func loadExportCheckpoint(path string) (*exportCheckpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	ckpt := &exportCheckpoint{}
+	if err := json.Unmarshal(data, ckpt); err != nil {
+		return nil, fmt.Errorf("parse %s: %v", path, err)
+	}
+	return ckpt, nil
+}
+
+func (ckpt *exportCheckpoint) save(path string) error {
+	ckpt.Updated = time.Now().Format(timeLayout)
+	return saveCheckpoint(path, ckpt)
+}
+
+// saveCheckpoint 先写临时文件再改名，中断时不会留下写了一半的 checkpoint
+func saveCheckpoint(path string, ckpt interface{}) error {
+	data, err := json.MarshalIndent(ckpt, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// handleExport 按 key 顺序把 t 范围内的数据写入文件，每 exportCheckpointRows 条更新一次 checkpoint，
+// 中断或出错后用 -resume 从最后写入的 key 之后继续
+func (c *TiKVClient) handleExport(t scanTask, o exportOptions) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	ckpt := &exportCheckpoint{
+		File:     o.file,
+		Format:   o.format,
+		Encoding: o.encoding,
+		Start:    encodeField(t.start, encodingAuto),
+		End:      encodeField(t.end, encodingAuto),
+		TS:       t.ts,
+	}
+	if o.resume {
+		prev, err := loadExportCheckpoint(o.checkpoint)
+		if err != nil {
+			fmt.Printf("read checkpoint err: %v\n", err)
+			c.status = ExitError
+			return
+		}
+		if prev.File != ckpt.File || prev.Start != ckpt.Start || prev.End != ckpt.End ||
+			prev.Format != ckpt.Format || prev.Encoding != ckpt.Encoding {
+			fmt.Printf("checkpoint %s belongs to another export: [%s, %s) -o %s -format=%s -encoding=%s\n",
+				o.checkpoint, prev.Start, prev.End, prev.File, prev.Format, prev.Encoding)
+			c.status = ExitError
+			return
+		}
+		ckpt = prev
+		t.ts = prev.TS
+		if prev.LastKey != "" {
+			lastKey, err := decodeField(prev.LastKey)
+			if err != nil {
+				fmt.Printf("read checkpoint err: lastKey: %v\n", err)
+				c.status = ExitError
+				return
+			}
+			t.start = append(lastKey, 0)
+			fmt.Printf("resuming after %s, %d keys already exported\n", c.fmtKey(lastKey), prev.Count)
+		}
+	} else if _, err := os.Stat(o.file); err == nil {
+		if !c.confirm(fmt.Sprintf("%s already exists, overwrite? (yes/no): ", o.file)) {
+			return
+		}
+	}
+
+	w, err := newDumpWriter(o.file, o.format, ckpt.Offset)
+	if err != nil {
+		fmt.Printf("open export file err: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	count := ckpt.Count
+	var lastKey []byte
+	save := func() error {
+		offset, err := w.sync()
+		if err != nil {
+			return fmt.Errorf("write export file err: %v", err)
+		}
+		ckpt.Offset, ckpt.Count = offset, count
+		if lastKey != nil {
+			ckpt.LastKey = encodeField(lastKey, encodingAuto)
+		}
+		if err := ckpt.save(o.checkpoint); err != nil {
+			return fmt.Errorf("write checkpoint err: %v", err)
+		}
+		return nil
+	}
+
+	startTime := time.Now()
+	t.ordered = true
+	var writeErr error
+	err = c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			return false
+		default:
+		}
+		if writeErr = w.write(dumpRecord{Key: encodeField(key, o.encoding), Value: encodeField(value, o.encoding)}); writeErr != nil {
+			writeErr = fmt.Errorf("write export file err: %v", writeErr)
+			return false
+		}
+		lastKey = key
+		count++
+		if count%exportCheckpointRows == 0 {
+			if writeErr = save(); writeErr != nil {
+				return false
+			}
+			fmt.Printf("exported: %d, last key: %s\n", count, c.fmtKey(key))
+		}
+		return true
+	})
+	t.filter.reportSkipped(c.fmtKey)
+	if err == nil {
+		err = writeErr
+	}
+
+	if err != nil || c.status == ExitCancelled {
+		if err != nil {
+			fmt.Printf("operation failed: %v\n", err)
+			c.status = ExitError
+		} else {
+			fmt.Println("\noperation cancelled")
+		}
+		if serr := save(); serr != nil {
+			fmt.Println(serr)
+		} else {
+			fmt.Printf("exported %d keys so far, checkpoint saved to %s, run the same command with -resume to continue\n", count, o.checkpoint)
+		}
+		w.close()
+		return
+	}
+	if err := w.close(); err != nil {
+		fmt.Printf("write export file err: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	os.Remove(o.checkpoint)
+	fmt.Println("Total exported:", count, "file:", o.file, "time consuming:", time.Since(startTime))
+}
@@ -0,0 +1,246 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+// import 的冲突策略，针对文件中的 key 已存在且值不同的情况
+const (
+	conflictOverwrite    = "overwrite"      // 覆盖，原值写入日志，可以 undo
+	conflictSkip         = "skip"           // 保留已有的值
+	conflictFail         = "fail"           // 先检查整个文件，有冲突时什么都不写
+	conflictOnlyIfAbsent = "only-if-absent" // 只写不存在的 key，已存在的不论值是否相同都不动
+)
+
+var conflictPolicies = []string{conflictOverwrite, conflictSkip, conflictFail, conflictOnlyIfAbsent}
+
+const (
+	importBatchSize  = 3000
+	importBatchBytes = 8 << 20 // 单个事务写入的数据量上限
+	maxConflictShown = 10
+)
+
+type importOptions struct {
+	format string
+	policy string
+	dryRun bool
+}
+
+// importStats 按是否写入和原有的值分类计数
+type importStats struct {
+	read        int
+	created     int // key 不存在，新写入
+	overwritten int
+	unchanged   int // 已有相同的值，不必写
+	skipped     int
+	conflicts   [][]byte // fail 策略下已存在且值不同的 key，最多 maxConflictShown 个
+	conflictN   int
+}
+
+func (s *importStats) add(o importStats) {
+	s.created += o.created
+	s.overwritten += o.overwritten
+	s.unchanged += o.unchanged
+	s.skipped += o.skipped
+	s.conflictN += o.conflictN
+	for _, k := range o.conflicts {
+		if len(s.conflicts) < maxConflictShown {
+			s.conflicts = append(s.conflicts, k)
+		}
+	}
+}
+
+func (s *importStats) written() int {
+	return s.created + s.overwritten
+}
+
+// classify 对照 key 已有的值统计一批记录，返回需要写入的记录
+func classify(batch []kvPair, existing map[string][]byte, policy string) (writes []kvPair, olds [][]byte, st importStats) {
+	for _, kv := range batch {
+		old, exists := existing[string(kv.key)]
+		switch {
+		case !exists:
+			st.created++
+		case policy == conflictOnlyIfAbsent:
+			st.skipped++
+			continue
+		case bytes.Equal(old, kv.value):
+			st.unchanged++
+			continue
+		case policy == conflictOverwrite:
+			st.overwritten++
+		case policy == conflictFail:
+			st.conflictN++
+			if len(st.conflicts) < maxConflictShown {
+				st.conflicts = append(st.conflicts, kv.key)
+			}
+			continue
+		default:
+			st.skipped++
+			continue
+		}
+		writes = append(writes, kv)
+		olds = append(olds, old)
+	}
+	return writes, olds, st
+}
+
+// handleImport 读取 export 格式的文件按批写入，每批在一个事务中先读已有的值再写，
+// 读与写之间被其他人写入的 key 会使提交失败，不会被悄悄覆盖
+func (c *TiKVClient) handleImport(path string, o importOptions) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	startTime := time.Now()
+	if o.dryRun || o.policy == conflictFail {
+		st, err := c.importPass(path, o, false, sigCh)
+		if err != nil {
+			c.importStopped(st, err)
+			return
+		}
+		if st.conflictN > 0 {
+			for _, k := range st.conflicts {
+				fmt.Printf("conflict: %s\n", c.fmtKey(k))
+			}
+			fmt.Printf("%d keys already exist with a different value, nothing imported\n", st.conflictN)
+			c.status = ExitError
+			return
+		}
+		if o.dryRun {
+			fmt.Println("dry run, nothing written")
+			fmt.Printf("read: %d, new: %d, overwrite: %d, unchanged: %d, skip: %d\n",
+				st.read, st.created, st.overwritten, st.unchanged, st.skipped)
+			return
+		}
+	}
+
+	if !c.confirm(fmt.Sprintf("Are you sure to import %s? (yes/no): ", path)) {
+		return
+	}
+	st, err := c.importPass(path, o, true, sigCh)
+	if err != nil {
+		c.importStopped(st, err)
+		return
+	}
+	fmt.Printf("Total read: %d, created: %d, overwritten: %d, unchanged: %d, skipped: %d, time consuming: %v\n",
+		st.read, st.created, st.overwritten, st.unchanged, st.skipped, time.Since(startTime))
+}
+
+var errImportCancelled = fmt.Errorf("operation cancelled")
+
+func (c *TiKVClient) importStopped(st *importStats, err error) {
+	if err == errImportCancelled {
+		fmt.Println("\noperation cancelled")
+		c.status = ExitCancelled
+	} else {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+	}
+	if st != nil && st.written() > 0 {
+		fmt.Printf("%d records were written before stopping\n", st.written())
+	}
+}
+
+// importPass 读一遍文件。write 为 false 时只对照当前数据统计，用于 -dry-run 和 fail 策略的预检查
+func (c *TiKVClient) importPass(path string, o importOptions, write bool, sigCh <-chan os.Signal) (*importStats, error) {
+	r, err := openDump(path, o.format)
+	if err != nil {
+		return nil, fmt.Errorf("open import file err: %v", err)
+	}
+	defer r.close()
+
+	ctx := context.Background()
+	st := &importStats{}
+	var batch []kvPair
+	size := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		keys := make([][]byte, len(batch))
+		for i, kv := range batch {
+			keys[i] = kv.key
+		}
+		if !write {
+			snap, err := c.snapshot(ctx, 0)
+			if err != nil {
+				return err
+			}
+			existing, err := snap.BatchGet(ctx, keys)
+			if err != nil {
+				return err
+			}
+			_, _, bst := classify(batch, existing, o.policy)
+			st.add(bst)
+			batch, size = batch[:0], 0
+			return nil
+		}
+
+		var writes []kvPair
+		var olds [][]byte
+		var bst importStats
+		// 新写入和覆盖的 key 都记录原值，undo 时删除新建的 key、恢复被覆盖的值
+		log := c.newAuditBatch()
+		err := c.executeTxn(func(txn *transaction.KVTxn) error {
+			log.reset()
+			existing, err := txn.BatchGet(ctx, keys)
+			if err != nil {
+				return err
+			}
+			writes, olds, bst = classify(batch, existing, o.policy)
+			if bst.conflictN > 0 {
+				return fmt.Errorf("key %s was changed during the import", c.fmtKey(bst.conflicts[0]))
+			}
+			for i, kv := range writes {
+				if err := txn.Set(kv.key, kv.value); err != nil {
+					return fmt.Errorf("set key=%s err: %v", c.fmtKey(kv.key), err)
+				}
+				log.set(kv.key, olds[i], kv.value)
+			}
+			return log.write()
+		})
+		if err != nil {
+			return err
+		}
+		st.add(bst)
+		log.committed(c.commitTS)
+		fmt.Printf("Batch written: %d, Total read: %d\n", len(writes), st.read)
+		batch, size = batch[:0], 0
+		return nil
+	}
+
+	for {
+		key, value, err := r.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return st, fmt.Errorf("read %s: %v", path, err)
+		}
+		st.read++
+		batch = append(batch, kvPair{key: key, value: value})
+		size += len(key) + len(value)
+		if len(batch) < importBatchSize && size < importBatchBytes {
+			continue
+		}
+		if err := flush(); err != nil {
+			return st, err
+		}
+		select {
+		case <-sigCh:
+			return st, errImportCancelled
+		default:
+		}
+	}
+	return st, flush()
+}
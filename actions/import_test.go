@@ -0,0 +1,68 @@
+package actions
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	batch := []kvPair{
+		{[]byte("new"), []byte("1")},
+		{[]byte("same"), []byte("2")},
+		{[]byte("changed"), []byte("3")},
+		{[]byte("empty"), []byte("")},
+	}
+	existing := map[string][]byte{
+		"same":    []byte("2"),
+		"changed": []byte("old"),
+		"empty":   {},
+	}
+	tests := []struct {
+		policy string
+		writes []string
+		olds   []string
+		st     importStats
+	}{
+		{conflictOverwrite, []string{"new", "changed"}, []string{"", "old"}, importStats{created: 1, overwritten: 1, unchanged: 2}},
+		{conflictSkip, []string{"new"}, []string{""}, importStats{created: 1, unchanged: 2, skipped: 1}},
+		{conflictFail, []string{"new"}, []string{""}, importStats{created: 1, unchanged: 2, conflictN: 1, conflicts: [][]byte{[]byte("changed")}}},
+		{conflictOnlyIfAbsent, []string{"new"}, []string{""}, importStats{created: 1, skipped: 3}},
+	}
+	for _, tt := range tests {
+		writes, olds, st := classify(batch, existing, tt.policy)
+		var gotWrites, gotOlds []string
+		for i, kv := range writes {
+			gotWrites = append(gotWrites, string(kv.key))
+			gotOlds = append(gotOlds, string(olds[i]))
+		}
+		if !reflect.DeepEqual(gotWrites, tt.writes) || !reflect.DeepEqual(gotOlds, tt.olds) {
+			t.Errorf("%s: writes %q olds %q, want %q %q", tt.policy, gotWrites, gotOlds, tt.writes, tt.olds)
+		}
+		if !reflect.DeepEqual(st, tt.st) {
+			t.Errorf("%s: stats %+v, want %+v", tt.policy, st, tt.st)
+		}
+	}
+}
+
+// fail 策略只记下前 maxConflictShown 个冲突的 key，计数包含全部
+func TestClassifyConflictLimit(t *testing.T) {
+	var batch []kvPair
+	existing := map[string][]byte{}
+	for i := 0; i < maxConflictShown+5; i++ {
+		k := fmt.Sprintf("k%02d", i)
+		batch = append(batch, kvPair{[]byte(k), []byte("new")})
+		existing[k] = []byte("old")
+	}
+	var total importStats
+	for _, part := range [][]kvPair{batch[:7], batch[7:]} {
+		_, _, st := classify(part, existing, conflictFail)
+		total.add(st)
+	}
+	if total.conflictN != len(batch) || len(total.conflicts) != maxConflictShown {
+		t.Errorf("conflictN = %d, %d shown, want %d, %d", total.conflictN, len(total.conflicts), len(batch), maxConflictShown)
+	}
+	if string(total.conflicts[0]) != "k00" || string(total.conflicts[maxConflictShown-1]) != fmt.Sprintf("k%02d", maxConflictShown-1) {
+		t.Errorf("conflicts = %q, want the first %d keys", total.conflicts, maxConflictShown)
+	}
+}
@@ -0,0 +1,252 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+const (
+	malformedShown  = 10 // 报告格式错误的锁记录时列出的 key 数
+	conflictRetries = 3  // locks reap 的一批因写冲突提交失败时最多执行的次数
+)
+
+// lockFilter locks expired/reap 选择锁记录的条件，时间都是毫秒
+type lockFilter struct {
+	at        int64  // 判断是否过期的时间，lockTime + maxDuration 早于它即过期
+	owner     string // 为空时不限
+	object    string // objectKey 前缀，为空时不限
+	olderThan int64  // lockTime 至少早于 at 这么久，0 表示不限
+}
+
+// match 满足条件且已过期
+func (f *lockFilter) match(d *Data) bool {
+	return f.expired(d) && f.selects(d)
+}
+
+func (f *lockFilter) expired(d *Data) bool {
+	return d.LockTime+d.MaxDuration < f.at
+}
+
+// selects 是否满足 owner、object 和 older 条件，不看是否过期
+func (f *lockFilter) selects(d *Data) bool {
+	return (f.owner == "" || d.Owner == f.owner) &&
+		strings.HasPrefix(d.ObjectKey, f.object) &&
+		(f.olderThan == 0 || d.LockTime <= f.at-f.olderThan)
+}
+
+// parseLock 解析锁记录，value 不是 JSON 对象或缺少 owner、lockTime、maxDuration 时返回错误
+func parseLock(value []byte) (*Data, error) {
+	var raw struct {
+		Owner       *string `json:"owner"`
+		LockTime    *int64  `json:"lockTime"`
+		MaxDuration *int64  `json:"maxDuration"`
+		ObjectKey   string  `json:"objectKey"`
+	}
+	if err := json.Unmarshal(value, &raw); err != nil {
+		return nil, fmt.Errorf("not a JSON lock record: %v", err)
+	}
+	if raw.Owner == nil || raw.LockTime == nil || raw.MaxDuration == nil {
+		return nil, fmt.Errorf("missing owner, lockTime or maxDuration")
+	}
+	return &Data{Owner: *raw.Owner, LockTime: *raw.LockTime, MaxDuration: *raw.MaxDuration, ObjectKey: raw.ObjectKey}, nil
+}
+
+// malformedLocks 扫描时跳过的无法解析的锁记录，只保留前 malformedShown 个
+type malformedLocks struct {
+	n       int
+	keys    [][]byte
+	reasons []string
+}
+
+func (m *malformedLocks) add(key []byte, err error) {
+	m.n++
+	if len(m.keys) < malformedShown {
+		m.keys = append(m.keys, key)
+		m.reasons = append(m.reasons, err.Error())
+	}
+}
+
+// report 写到 stderr 以免混入 json/csv 输出
+func (m *malformedLocks) report(fmtKey func([]byte) string) {
+	if m.n == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "skipped %d malformed lock record(s):\n", m.n)
+	for i, k := range m.keys {
+		fmt.Fprintf(os.Stderr, "  %s: %s\n", fmtKey(k), m.reasons[i])
+	}
+	if m.n > len(m.keys) {
+		fmt.Fprintf(os.Stderr, "  ... and %d more\n", m.n-len(m.keys))
+	}
+}
+
+// scanLocks 扫描 t 范围内的锁记录，把 match 选中的交给 emit；无法解析的记录计入 bad 后跳过。
+// 收到中断信号时停止并将 c.status 置为 ExitCancelled
+func (c *TiKVClient) scanLocks(t scanTask, match func(d *Data) bool, bad *malformedLocks, sigCh <-chan os.Signal, emit func(key, value []byte, d *Data) bool) error {
+	return c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			return false
+		default:
+		}
+		d, err := parseLock(value)
+		if err != nil {
+			bad.add(key, err)
+			return true
+		}
+		if !match(d) {
+			return true
+		}
+		return emit(key, value, d)
+	})
+}
+
+// handleLocksExpired 按 key 顺序列出已过期的锁记录，limit > 0 时最多 limit 个
+func (c *TiKVClient) handleLocksExpired(t scanTask, f *lockFilter, limit int, p *printer) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	t.ordered = true
+	var bad malformedLocks
+	count := 0
+	err := c.scanLocks(t, f.match, &bad, sigCh, func(key, value []byte, _ *Data) bool {
+		p.row(key, value)
+		count++
+		return limit <= 0 || count < limit
+	})
+	p.finish(count)
+	bad.report(c.fmtKey)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
+	}
+}
+
+// isWriteConflict 提交时 key 已被其他事务修改
+func isWriteConflict(err error) bool {
+	if err == nil {
+		return false
+	}
+	return tikverr.IsErrWriteConflict(err) || strings.Contains(err.Error(), "write conflict")
+}
+
+// reapLocks 删除 match 选中的锁记录。扫描按 region 并发，删除按批提交：
+// 每批在删除的事务中重新读取，只删除值与扫描时相同的记录，期间被续期、改写或删除的跳过
+func (c *TiKVClient) reapLocks(t scanTask, match func(d *Data) bool, limit int, th *throttle) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	if !c.confirm("Are you sure to delete? (yes/no): ") {
+		return
+	}
+
+	deletedTotal, changed, notReaped := 0, 0, 0 // notReaped 一直写冲突而放弃的锁
+	startTime := time.Now()
+	var batch []kvPair
+	log := c.newAuditBatch()
+	flush := func() bool {
+		if len(batch) == 0 {
+			return true
+		}
+		var deleted []kvPair
+		reap := func(txn *transaction.KVTxn) (int, int64, error) {
+			deleted = deleted[:0]
+			log.reset()
+			keys := make([][]byte, len(batch))
+			for i, kv := range batch {
+				keys[i] = kv.key
+			}
+			current, err := txn.BatchGet(context.Background(), keys)
+			if err != nil {
+				return 0, 0, fmt.Errorf("batch get err: %v", err)
+			}
+			var size int64
+			for _, kv := range batch {
+				if v, ok := current[string(kv.key)]; !ok || !bytes.Equal(v, kv.value) {
+					continue
+				}
+				if err := txn.Delete(kv.key); err != nil {
+					return 0, 0, fmt.Errorf("delete key=%s err: %v", c.fmtKey(kv.key), err)
+				}
+				deleted = append(deleted, kv)
+				log.deleted(kv.key, kv.value)
+				size += int64(len(kv.key) + len(kv.value))
+			}
+			return len(deleted), size, log.write()
+		}
+		err := th.execute(c.begin, reap)
+		// 重新读取之后、提交之前又被续期或改写的锁会使提交因写冲突失败，
+		// 这时重做这一批，只删除仍未变的记录；一直冲突时整批放弃，不算作已变化，单独报告后继续下一批
+		for retry := 1; isWriteConflict(err) && retry < conflictRetries; retry++ {
+			err = th.execute(c.begin, reap)
+		}
+		if isWriteConflict(err) {
+			fmt.Printf("gave up %d lock(s) that kept changing while being deleted\n", len(batch))
+			notReaped += len(batch)
+			batch = batch[:0]
+			return true
+		}
+		if err != nil {
+			fmt.Println(err)
+			c.status = ExitError
+			return false
+		}
+		log.committed(c.commitTS)
+		changed += len(batch) - len(deleted)
+		deletedTotal += len(deleted)
+		if len(deleted) > 0 {
+			fmt.Printf("Batch deleted: %d, Total deleted: %d%s\n", len(deleted), deletedTotal, th.progress())
+		}
+		batch = batch[:0]
+		return true
+	}
+
+	t.ordered = limit > 0
+	var bad malformedLocks
+	err := c.scanLocks(t, match, &bad, sigCh, func(key, value []byte, _ *Data) bool {
+		batch = append(batch, kvPair{key: key, value: value})
+		if len(batch) >= th.batchSize(deleteBatchSize) && !flush() {
+			return false
+		}
+		return limit <= 0 || deletedTotal+changed+notReaped+len(batch) < limit
+	})
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+	}
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
+	}
+	if c.status == ExitOK {
+		flush()
+	}
+	bad.report(c.fmtKey)
+	if changed > 0 {
+		fmt.Printf("skipped %d lock(s) changed or removed since they were read\n", changed)
+	}
+	if notReaped > 0 {
+		fmt.Printf("not reaped: %d lock(s) kept changing while being deleted, run locks reap again to retry them\n", notReaped)
+	}
+	if c.status != ExitOK {
+		return
+	}
+	fmt.Println("Total deleted:", deletedTotal, "time consuming:", time.Since(startTime))
+	th.printSummary()
+}
@@ -0,0 +1,29 @@
+package actions
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/pingcap/kvproto/pkg/kvrpcpb"
+	tikverr "github.com/tikv/client-go/v2/error"
+)
+
+func TestIsWriteConflict(t *testing.T) {
+	conflict := tikverr.NewErrWriteConflictWithArgs(1, 2, 3, []byte("OS/T03/Data/Lock/1"), kvrpcpb.WriteConflict_Optimistic)
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{nil, false},
+		{conflict, true},
+		{fmt.Errorf("transation commit err: %w", conflict), true},
+		{errors.New("transation commit err: write conflict { start_ts:1 }"), true},
+		{tikverr.ErrTiKVServerBusy, false},
+	}
+	for _, tt := range tests {
+		if got := isWriteConflict(tt.err); got != tt.want {
+			t.Errorf("isWriteConflict(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
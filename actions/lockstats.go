@@ -0,0 +1,213 @@
+package actions
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"tikv/utils"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+// lockStats locks stats 的结果，时间都是毫秒，json 输出时按原样给出
+type lockStats struct {
+	Tenant       string          `json:"tenant"`
+	At           string          `json:"at"` // 判断是否过期的时间
+	Total        int             `json:"total"`
+	Expired      int             `json:"expired"`
+	Malformed    int             `json:"malformed"`
+	Oldest       *lockStatsKey   `json:"oldest,omitempty"`
+	Newest       *lockStatsKey   `json:"newest,omitempty"`
+	Owners       []*ownerStats   `json:"owners"`
+	MaxDurations []*durationStat `json:"maxDurations"`
+}
+
+// lockStatsKey 最早或最晚加的锁
+type lockStatsKey struct {
+	Key      string `json:"key"`
+	LockTime int64  `json:"lockTime"`
+	Time     string `json:"time"`
+}
+
+type ownerStats struct {
+	Owner   string `json:"owner"`
+	Count   int    `json:"count"`
+	Expired int    `json:"expired"`
+	Oldest  int64  `json:"oldestLockTime"`
+	Newest  int64  `json:"newestLockTime"`
+}
+
+type durationStat struct {
+	MaxDuration int64  `json:"maxDuration"`
+	Duration    string `json:"duration"`
+	Count       int    `json:"count"`
+}
+
+// lockStatsBuilder 逐条累计锁记录，结果与记录到达的先后无关
+type lockStatsBuilder struct {
+	st                   *lockStats
+	f                    *lockFilter
+	owners               map[string]*ownerStats
+	durations            map[int64]int
+	oldestKey, newestKey []byte
+}
+
+func newLockStatsBuilder(tenant string, f *lockFilter) *lockStatsBuilder {
+	return &lockStatsBuilder{
+		st:        &lockStats{Tenant: tenant, At: utils.FormatMillis(f.at)},
+		f:         f,
+		owners:    map[string]*ownerStats{},
+		durations: map[int64]int{},
+	}
+}
+
+func (b *lockStatsBuilder) add(key []byte, d *Data) {
+	st := b.st
+	st.Total++
+	o := b.owners[d.Owner]
+	if o == nil {
+		o = &ownerStats{Owner: d.Owner, Oldest: d.LockTime, Newest: d.LockTime}
+		b.owners[d.Owner] = o
+	}
+	o.Count++
+	o.Oldest, o.Newest = min(o.Oldest, d.LockTime), max(o.Newest, d.LockTime)
+	if b.f.expired(d) {
+		st.Expired++
+		o.Expired++
+	}
+	b.durations[d.MaxDuration]++
+	// 加锁时间相同时取 key 较小的，结果与扫描的先后无关
+	if st.Oldest == nil || d.LockTime < st.Oldest.LockTime || d.LockTime == st.Oldest.LockTime && bytes.Compare(key, b.oldestKey) < 0 {
+		st.Oldest, b.oldestKey = &lockStatsKey{LockTime: d.LockTime}, key
+	}
+	if st.Newest == nil || d.LockTime > st.Newest.LockTime || d.LockTime == st.Newest.LockTime && bytes.Compare(key, b.newestKey) < 0 {
+		st.Newest, b.newestKey = &lockStatsKey{LockTime: d.LockTime}, key
+	}
+}
+
+// finish 补全最早和最晚的 key，owner 按锁数从多到少、相同时按名字排序，maxDuration 从小到大排序
+func (b *lockStatsBuilder) finish(malformed int, fmtKey func([]byte) string) *lockStats {
+	st := b.st
+	st.Malformed = malformed
+	for _, k := range []struct {
+		s   *lockStatsKey
+		key []byte
+	}{{st.Oldest, b.oldestKey}, {st.Newest, b.newestKey}} {
+		if k.s != nil {
+			k.s.Key, k.s.Time = fmtKey(k.key), utils.FormatMillis(k.s.LockTime)
+		}
+	}
+	st.Owners = make([]*ownerStats, 0, len(b.owners))
+	for _, o := range b.owners {
+		st.Owners = append(st.Owners, o)
+	}
+	sort.Slice(st.Owners, func(i, j int) bool {
+		a, b := st.Owners[i], st.Owners[j]
+		return a.Count > b.Count || a.Count == b.Count && a.Owner < b.Owner
+	})
+	st.MaxDurations = make([]*durationStat, 0, len(b.durations))
+	for d, n := range b.durations {
+		st.MaxDurations = append(st.MaxDurations, &durationStat{MaxDuration: d, Duration: formatMillisDuration(d), Count: n})
+	}
+	sort.Slice(st.MaxDurations, func(i, j int) bool { return st.MaxDurations[i].MaxDuration < st.MaxDurations[j].MaxDuration })
+	return st
+}
+
+// handleLockStats 统计 t 范围内的锁记录：各 owner 的锁数和加锁时间、maxDuration 的分布以及过期的数量
+func (c *TiKVClient) handleLockStats(tenant string, t scanTask, f *lockFilter, format string) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	b := newLockStatsBuilder(tenant, f)
+	var bad malformedLocks
+	err := c.scanLocks(t, f.selects, &bad, sigCh, func(key, value []byte, d *Data) bool {
+		b.add(key, d)
+		return true
+	})
+	bad.report(c.fmtKey)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
+		return
+	}
+
+	st := b.finish(bad.n, c.fmtKey)
+	if format == formatJSON {
+		data, _ := json.Marshal(st)
+		fmt.Println(string(data))
+		return
+	}
+	st.print()
+}
+
+func (st *lockStats) print() {
+	fmt.Printf("tenant: %s, expiry checked at %s\n", st.Tenant, st.At)
+	fmt.Printf("locks: %d, expired: %d, malformed: %d\n", st.Total, st.Expired, st.Malformed)
+	if st.Total == 0 {
+		return
+	}
+	fmt.Printf("oldest: %s  %s\n", st.Oldest.Time, st.Oldest.Key)
+	fmt.Printf("newest: %s  %s\n", st.Newest.Time, st.Newest.Key)
+
+	fmt.Println()
+	rows := [][]string{{"OWNER", "LOCKS", "EXPIRED", "OLDEST", "NEWEST"}}
+	for _, o := range st.Owners {
+		rows = append(rows, []string{o.Owner, strconv.Itoa(o.Count), strconv.Itoa(o.Expired), utils.FormatMillis(o.Oldest), utils.FormatMillis(o.Newest)})
+	}
+	printAligned(rows)
+
+	fmt.Println()
+	rows = [][]string{{"MAX DURATION", "LOCKS", "SHARE"}}
+	for _, d := range st.MaxDurations {
+		rows = append(rows, []string{d.Duration, strconv.Itoa(d.Count), fmt.Sprintf("%.1f%%", float64(d.Count)*100/float64(st.Total))})
+	}
+	printAligned(rows)
+}
+
+// printAligned 按显示宽度对齐输出各列
+func printAligned(rows [][]string) {
+	widths := make([]int, len(rows[0]))
+	for _, r := range rows {
+		for i, col := range r {
+			widths[i] = max(widths[i], runewidth.StringWidth(col))
+		}
+	}
+	for _, r := range rows {
+		var sb strings.Builder
+		for i, col := range r {
+			sb.WriteString(col)
+			if i < len(r)-1 {
+				sb.WriteString(strings.Repeat(" ", widths[i]-runewidth.StringWidth(col)+2))
+			}
+		}
+		fmt.Println(sb.String())
+	}
+}
+
+// formatMillisDuration 以毫秒计的时长，整天时显示为 3d，否则如 1h30m
+func formatMillisDuration(ms int64) string {
+	d := time.Duration(ms) * time.Millisecond
+	if day := 24 * time.Hour; d > 0 && d%day == 0 {
+		return fmt.Sprintf("%dd", d/day)
+	}
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
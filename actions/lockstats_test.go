@@ -0,0 +1,76 @@
+package actions
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestLockStatsBuilder(t *testing.T) {
+	const hour = int64(3600 * 1000)
+	at := 100 * hour
+	locks := []struct {
+		key string
+		d   Data
+	}{
+		{"L/a", Data{Owner: "C003", LockTime: 10 * hour, MaxDuration: 24 * hour}}, // 过期
+		{"L/b", Data{Owner: "C001", LockTime: 90 * hour, MaxDuration: 24 * hour}}, // 未过期
+		{"L/c", Data{Owner: "C002", LockTime: 10 * hour, MaxDuration: hour}},      // 过期，与 L/a 同为最早
+		{"L/d", Data{Owner: "C001", LockTime: 95 * hour, MaxDuration: hour}},      // 过期，最晚
+		{"L/e", Data{Owner: "C002", LockTime: 95 * hour, MaxDuration: 72 * hour}}, // 未过期，与 L/d 同为最晚
+		{"L/f", Data{Owner: "C003", LockTime: 50 * hour, MaxDuration: 24 * hour}}, // 过期
+	}
+	build := func(order []int) *lockStats {
+		b := newLockStatsBuilder("OS/T03", &lockFilter{at: at})
+		for _, i := range order {
+			d := locks[i].d
+			b.add([]byte(locks[i].key), &d)
+		}
+		return b.finish(2, func(k []byte) string { return string(k) })
+	}
+
+	st := build([]int{0, 1, 2, 3, 4, 5})
+	if st.Total != 6 || st.Expired != 4 || st.Malformed != 2 {
+		t.Errorf("total %d, expired %d, malformed %d, want 6, 4, 2", st.Total, st.Expired, st.Malformed)
+	}
+	// 加锁时间相同时取 key 较小的
+	if st.Oldest.Key != "L/a" || st.Oldest.LockTime != 10*hour || st.Newest.Key != "L/d" || st.Newest.LockTime != 95*hour {
+		t.Errorf("oldest %+v, newest %+v, want L/a and L/d", st.Oldest, st.Newest)
+	}
+	// 锁数相同的 owner 按名字排序
+	wantOwners := []ownerStats{
+		{Owner: "C001", Count: 2, Expired: 1, Oldest: 90 * hour, Newest: 95 * hour},
+		{Owner: "C002", Count: 2, Expired: 1, Oldest: 10 * hour, Newest: 95 * hour},
+		{Owner: "C003", Count: 2, Expired: 2, Oldest: 10 * hour, Newest: 50 * hour},
+	}
+	var owners []ownerStats
+	for _, o := range st.Owners {
+		owners = append(owners, *o)
+	}
+	if !reflect.DeepEqual(owners, wantOwners) {
+		t.Errorf("owners = %+v, want %+v", owners, wantOwners)
+	}
+	var durations []string
+	for _, d := range st.MaxDurations {
+		durations = append(durations, d.Duration)
+		if d.Duration == "1d" && d.Count != 3 {
+			t.Errorf("1d: %d locks, want 3", d.Count)
+		}
+	}
+	if !reflect.DeepEqual(durations, []string{"1h", "1d", "3d"}) {
+		t.Errorf("maxDurations = %v, want 1h 1d 3d", durations)
+	}
+
+	// 各段扫描完成的先后不同，结果也相同
+	want, _ := json.Marshal(st)
+	for _, order := range [][]int{{5, 4, 3, 2, 1, 0}, {3, 0, 4, 2, 5, 1}} {
+		if got, _ := json.Marshal(build(order)); string(got) != string(want) {
+			t.Errorf("order %v:\n%s\nwant\n%s", order, got, want)
+		}
+	}
+
+	st = newLockStatsBuilder("OS/T03", &lockFilter{at: at}).finish(0, nil)
+	if st.Total != 0 || st.Oldest != nil || len(st.Owners) != 0 || len(st.MaxDurations) != 0 {
+		t.Errorf("empty stats = %+v", st)
+	}
+}
@@ -0,0 +1,189 @@
+package actions
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"tikv/utils"
+)
+
+// keyFilter find/fd/count 等扫描命令的过滤条件，给出的条件全部满足才算匹配
+type keyFilter struct {
+	value    []byte         // -value，value 中包含
+	valueRe  *regexp.Regexp // -value 加 -icase 时改用正则
+	match    *regexp.Regexp // -match，value 正则
+	keyMatch *regexp.Regexp // -key-match，key 正则
+	glob     string         // 前缀参数中带 * 时按段匹配整个 key，如 OS/*/Data/Lock/*
+	where    *utils.Where   // -where，对 JSON value 求值
+
+	mu       sync.Mutex // worker 并发调用 matches，保护下面两项
+	notJSON  int        // -where 时 value 不是 JSON 而跳过的 key 数
+	firstBad []byte     // 第一个被跳过的 key
+}
+
+// newKeyFilter 由 -value、-match、-key-match、-icase、-where 和 glob 生成过滤条件，没有条件时返回 nil
+func newKeyFilter(in *input, glob string) (*keyFilter, error) {
+	f := &keyFilter{glob: glob}
+	if in.has("where") {
+		w, err := utils.ParseWhere(in.str("where"))
+		if err != nil {
+			return nil, err
+		}
+		f.where = w
+	}
+	icase := in.bool("icase")
+	if v := in.str("value"); v != "" {
+		if icase {
+			f.valueRe = regexp.MustCompile("(?i)" + regexp.QuoteMeta(v))
+		} else {
+			f.value = []byte(v)
+		}
+	}
+	var err error
+	if f.match, err = compileMatch(in, "match", icase); err != nil {
+		return nil, err
+	}
+	if f.keyMatch, err = compileMatch(in, "key-match", icase); err != nil {
+		return nil, err
+	}
+	if f.value == nil && f.valueRe == nil && f.match == nil && f.keyMatch == nil && f.glob == "" && f.where == nil {
+		return nil, nil
+	}
+	return f, nil
+}
+
+func compileMatch(in *input, name string, icase bool) (*regexp.Regexp, error) {
+	if !in.has(name) {
+		return nil, nil
+	}
+	expr := in.str(name)
+	if icase {
+		expr = "(?i)" + expr
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -%s: %v", name, err)
+	}
+	return re, nil
+}
+
+// splitGlob 前缀参数中有 * 时返回 glob 和第一个通配符之前的前缀
+func splitGlob(prefix string) (glob, literal string, err error) {
+	if !strings.Contains(prefix, "*") {
+		return "", prefix, nil
+	}
+	if _, err := path.Match(prefix, ""); err != nil {
+		return "", "", fmt.Errorf("invalid key pattern %s: %v", prefix, err)
+	}
+	return prefix, prefix[:strings.IndexAny(prefix, "*?[")], nil
+}
+
+func (f *keyFilter) matches(key, value []byte) bool {
+	if f.glob != "" {
+		if ok, _ := path.Match(f.glob, string(key)); !ok {
+			return false
+		}
+	}
+	if f.keyMatch != nil && !f.keyMatch.Match(key) {
+		return false
+	}
+	if f.value != nil && !bytes.Contains(value, f.value) {
+		return false
+	}
+	if f.valueRe != nil && !f.valueRe.Match(value) {
+		return false
+	}
+	if f.match != nil && !f.match.Match(value) {
+		return false
+	}
+	if f.where != nil {
+		ok, err := f.where.Match(value)
+		if err != nil {
+			f.mu.Lock()
+			if f.notJSON == 0 {
+				f.firstBad = append([]byte(nil), key...)
+			}
+			f.notJSON++
+			f.mu.Unlock()
+			return false
+		}
+		return ok
+	}
+	return true
+}
+
+// reportSkipped 提示 -where 时因 value 不是 JSON 而跳过的 key，写到 stderr 以免混入 json/csv 输出
+func (f *keyFilter) reportSkipped(fmtKey func([]byte) string) {
+	if f == nil || f.notJSON == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "-where: skipped %d key(s) whose value is not JSON, e.g. %s\n", f.notJSON, fmtKey(f.firstBad))
+}
+
+// needValue 是否需要读取 value
+func (f *keyFilter) needValue() bool {
+	return f.value != nil || f.valueRe != nil || f.match != nil || f.where != nil
+}
+
+// valuePatterns 用于在输出中高亮 value 的正则
+func (f *keyFilter) valuePatterns() []*regexp.Regexp {
+	var res []*regexp.Regexp
+	if f.value != nil {
+		res = append(res, regexp.MustCompile(regexp.QuoteMeta(string(f.value))))
+	}
+	for _, re := range []*regexp.Regexp{f.valueRe, f.match} {
+		if re != nil {
+			res = append(res, re)
+		}
+	}
+	return res
+}
+
+const (
+	colorMatch = "\x1b[1;31m"
+	colorReset = "\x1b[0m"
+)
+
+// colorEnabled 只在输出到终端时加颜色，设置 NO_COLOR 时关闭
+func colorEnabled() bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// highlight 给 text 中被任一正则匹配的部分加颜色，多个正则的匹配区间合并后一次输出
+func highlight(text string, res []*regexp.Regexp) string {
+	if len(res) == 0 {
+		return text
+	}
+	marked := make([]bool, len(text))
+	any := false
+	for _, re := range res {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			for i := loc[0]; i < loc[1]; i++ {
+				marked[i] = true
+				any = true
+			}
+		}
+	}
+	if !any {
+		return text
+	}
+	var sb strings.Builder
+	for i := 0; i < len(text); i++ {
+		if marked[i] && (i == 0 || !marked[i-1]) {
+			sb.WriteString(colorMatch)
+		}
+		sb.WriteByte(text[i])
+		if marked[i] && (i == len(text)-1 || !marked[i+1]) {
+			sb.WriteString(colorReset)
+		}
+	}
+	return sb.String()
+}
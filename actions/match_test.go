@@ -0,0 +1,92 @@
+package actions
+
+import (
+	"testing"
+	"tikv/utils"
+)
+
+func TestSplitGlob(t *testing.T) {
+	tests := []struct {
+		prefix, glob, literal string
+		wantErr               bool
+	}{
+		{"OS/T03/", "", "OS/T03/", false},
+		{"OS/*/Data/Lock/*", "OS/*/Data/Lock/*", "OS/", false},
+		{"OS/T0?/*", "OS/T0?/*", "OS/T0", false},
+		{"OS/T[0-3]/*", "OS/T[0-3]/*", "OS/T", false},
+		{"OS/[*", "", "", true},
+	}
+	for _, tt := range tests {
+		glob, literal, err := splitGlob(tt.prefix)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("splitGlob(%q) error = %v, want error %v", tt.prefix, err, tt.wantErr)
+			continue
+		}
+		if glob != tt.glob || literal != tt.literal {
+			t.Errorf("splitGlob(%q) = %q, %q, want %q, %q", tt.prefix, glob, literal, tt.glob, tt.literal)
+		}
+	}
+}
+
+// 各条件全部满足才匹配，-icase 同时作用于 -value、-match 和 -key-match
+func TestKeyFilterMatches(t *testing.T) {
+	tests := []struct {
+		line       string
+		glob       string
+		key, value string
+		want       bool
+	}{
+		{"find OS/", "OS/*/Data/Lock/*", "OS/T03/Data/Lock/a", "", true},
+		{"find OS/", "OS/*/Data/Lock/*", "OS/T03/Data/Lock/a/b", "", false},
+		{"find OS/", "OS/*/Data/Lock/*", "OS/T03/Data/Meta/a", "", false},
+		{"find OS/ -key-match=/Lock/[0-9]+$", "", "OS/T03/Data/Lock/42", "", true},
+		{"find OS/ -key-match=/Lock/[0-9]+$", "", "OS/T03/Data/Lock/x42", "", false},
+		{"find OS/ -key-match=/lock/", "", "OS/T03/Data/Lock/42", "", false},
+		{"find OS/ -key-match=/lock/ -icase", "", "OS/T03/Data/Lock/42", "", true},
+		{"find OS/ -value=C003", "", "k", `{"owner":"C003"}`, true},
+		{"find OS/ -value=c003", "", "k", `{"owner":"C003"}`, false},
+		{"find OS/ -value=c003 -icase", "", "k", `{"owner":"C003"}`, true},
+		{"find OS/ -value=a.c -icase", "", "k", "abc", false},
+		{"find OS/ -match=^C00[0-9]$", "", "k", "C003", true},
+		{"find OS/ -match=^c00[0-9]$ -icase", "", "k", "C003", true},
+		{"find OS/ -value=C003 -key-match=T04", "", "OS/T03/x", "C003", false},
+		{"find OS/ -value=C003 -key-match=T03", "OS/*/x", "OS/T03/x", "C003", true},
+	}
+	for _, tt := range tests {
+		cl, err := utils.ParseCommandLine(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd, _ := lookupCommand(cl.Args()[0])
+		in, err := cmd.parse(cl, nil)
+		if err != nil {
+			t.Fatalf("parse(%q): %v", tt.line, err)
+		}
+		f, err := newKeyFilter(in, tt.glob)
+		if err != nil {
+			t.Fatalf("newKeyFilter(%q): %v", tt.line, err)
+		}
+		if got := f.matches([]byte(tt.key), []byte(tt.value)); got != tt.want {
+			t.Errorf("%q glob %q: matches(%q, %q) = %v, want %v", tt.line, tt.glob, tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestNewKeyFilterNone(t *testing.T) {
+	cl, _ := utils.ParseCommandLine("find OS/ -icase")
+	cmd, _ := lookupCommand("find")
+	in, err := cmd.parse(cl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, err := newKeyFilter(in, ""); f != nil || err != nil {
+		t.Errorf("newKeyFilter without conditions = %v, %v, want nil", f, err)
+	}
+	cl, _ = utils.ParseCommandLine("find OS/ -key-match=(")
+	if in, err = cmd.parse(cl, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := newKeyFilter(in, ""); err == nil {
+		t.Error("newKeyFilter should reject an invalid -key-match")
+	}
+}
@@ -0,0 +1,326 @@
+package actions
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/mattn/go-runewidth"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"tikv/utils"
+)
+
+// 输出格式
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+	formatJSONL = "jsonl"
+	formatCSV   = "csv"
+	formatRaw   = "raw"
+)
+
+var outputFormats = []string{formatTable, formatJSON, formatJSONL, formatCSV, formatRaw}
+
+// table 格式每攒够这么多行按显示宽度对齐输出一次，避免大范围扫描时占用过多内存
+const tablePageRows = 1000
+
+// item json/jsonl 中的一条记录
+type item struct {
+	Key     string          `json:"key"`
+	Value   *string         `json:"value,omitempty"`
+	Size    *int            `json:"size,omitempty"`
+	Decoded json.RawMessage `json:"decoded,omitempty"` // value 是 JSON 时的解析结果
+	Found   *bool           `json:"found,omitempty"`
+}
+
+// printer 按输出格式打印读命令的结果
+type printer struct {
+	format string
+	pv     bool // 输出 value
+	single bool // 只有一条记录（get），json 格式直接输出对象
+	status bool // 输出 found 状态，多条记录时 table/csv/raw 增加一列
+	kfmt   string
+	vfmt   string
+
+	w       *bufio.Writer
+	csv     *csv.Writer
+	rows    [][]string // table 格式待对齐的行
+	header  bool       // table 表头是否已输出
+	started bool
+	count   int    // 已输出的记录数
+	lastKey []byte // 最后输出的 key，用于 next 翻页
+
+	keyHL   []*regexp.Regexp // table 输出到终端时高亮 key 中匹配的部分
+	valueHL []*regexp.Regexp
+}
+
+// ValidFormat 检查输出格式名称
+func ValidFormat(format string) error {
+	for _, f := range outputFormats {
+		if f == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown output format %s, use one of %s", format, strings.Join(outputFormats, "|"))
+}
+
+// outputFormat 命令的 -o 优先，其次是启动参数 -o，默认 table
+func (c *TiKVClient) outputFormat(in *input) string {
+	if in.has("o") {
+		return in.str("o")
+	}
+	if c.Output != "" {
+		return c.Output
+	}
+	return formatTable
+}
+
+func (c *TiKVClient) newPrinter(in *input, pv bool) *printer {
+	p := &printer{format: c.outputFormat(in), pv: pv, kfmt: c.kfmt, vfmt: c.vfmt, w: bufio.NewWriter(os.Stdout)}
+	if p.format == formatCSV {
+		p.csv = csv.NewWriter(p.w)
+	}
+	// raw 格式用于管道，未指定 -vfmt 时 value 按原始字节输出
+	if p.format == formatRaw && p.vfmt == "" {
+		p.vfmt = utils.FmtUTF8
+	}
+	return p
+}
+
+// highlightMatches table 格式输出到终端时高亮 f 匹配到的部分
+func (p *printer) highlightMatches(f *keyFilter) {
+	if f == nil || p.format != formatTable || !colorEnabled() {
+		return
+	}
+	if f.keyMatch != nil {
+		p.keyHL = []*regexp.Regexp{f.keyMatch}
+	}
+	if p.pv {
+		p.valueHL = f.valuePatterns()
+	}
+}
+
+func (p *printer) key(key []byte) string {
+	return utils.FormatBytes(key, p.kfmt)
+}
+
+func (p *printer) value(value []byte) string {
+	return utils.FormatBytes(value, p.vfmt)
+}
+
+func (p *printer) newItem(key, value []byte, found bool) item {
+	it := item{Key: p.key(key)}
+	if p.pv && found {
+		v := p.value(value)
+		size := len(value)
+		it.Value, it.Size = &v, &size
+		if json.Valid(value) {
+			it.Decoded = value
+		}
+	}
+	if p.status {
+		it.Found = &found
+	}
+	return it
+}
+
+// statusText table/csv/raw 中的状态列
+func statusText(found bool) string {
+	if found {
+		return "found"
+	}
+	return "missing"
+}
+
+func (p *printer) writeJSON(v interface{}) {
+	data, _ := json.Marshal(v)
+	p.w.Write(data)
+}
+
+// row 输出一条记录
+func (p *printer) row(key, value []byte) {
+	p.record(key, value, true)
+}
+
+// missing 输出不存在的 key（get、mget）
+func (p *printer) missing(key []byte) {
+	if !p.single {
+		p.record(key, nil, false)
+		return
+	}
+	switch p.format {
+	case formatJSON, formatJSONL:
+		p.writeJSON(p.newItem(key, nil, false))
+		p.w.WriteString("\n")
+	default:
+		fmt.Fprintf(p.w, "key:%s  not exist\n", p.key(key))
+	}
+}
+
+func (p *printer) record(key, value []byte, found bool) {
+	// 多条记录时状态单独成列
+	statusCol := p.status && !p.single
+	switch p.format {
+	case formatJSON:
+		if p.single {
+			p.writeJSON(p.newItem(key, value, found))
+			p.w.WriteString("\n")
+			break
+		}
+		if !p.started {
+			p.w.WriteString(`{"items":[`)
+		} else {
+			p.w.WriteString(",")
+		}
+		p.w.WriteString("\n")
+		p.writeJSON(p.newItem(key, value, found))
+	case formatJSONL:
+		p.writeJSON(p.newItem(key, value, found))
+		p.w.WriteString("\n")
+	case formatCSV:
+		if !p.started {
+			header := []string{"key"}
+			if statusCol {
+				header = append(header, "status")
+			}
+			if p.pv {
+				header = append(header, "value", "size")
+			}
+			_ = p.csv.Write(header)
+		}
+		rec := []string{p.key(key)}
+		if statusCol {
+			rec = append(rec, statusText(found))
+		}
+		if p.pv && found {
+			rec = append(rec, p.value(value), strconv.Itoa(len(value)))
+		} else if p.pv {
+			rec = append(rec, "", "")
+		}
+		_ = p.csv.Write(rec)
+	case formatRaw:
+		if p.single {
+			p.w.WriteString(p.value(value))
+		} else {
+			p.w.WriteString(p.key(key))
+			if statusCol {
+				p.w.WriteString("\t")
+				p.w.WriteString(statusText(found))
+			}
+			if p.pv {
+				p.w.WriteString("\t")
+				p.w.WriteString(p.value(value))
+			}
+		}
+		p.w.WriteString("\n")
+	default:
+		rec := []string{p.key(key)}
+		if statusCol {
+			rec = append(rec, statusText(found))
+		}
+		if p.pv {
+			rec = append(rec, p.value(value))
+		}
+		p.rows = append(p.rows, rec)
+		if len(p.rows) >= tablePageRows {
+			p.flushTable()
+		}
+	}
+	p.started = true
+	p.count++
+	p.lastKey = append(p.lastKey[:0], key...)
+}
+
+func (p *printer) flushTable() {
+	if len(p.rows) == 0 {
+		return
+	}
+	withHeader := !p.header
+	if withHeader {
+		header := []string{"KEY"}
+		if p.status && !p.single {
+			header = append(header, "STATUS")
+		}
+		if p.pv {
+			header = append(header, "VALUE")
+		}
+		p.rows = append([][]string{header}, p.rows...)
+		p.header = true
+	}
+	widths := make([]int, len(p.rows[0]))
+	for _, r := range p.rows {
+		for i, col := range r {
+			if w := runewidth.StringWidth(col); w > widths[i] {
+				widths[i] = w
+			}
+		}
+	}
+	for n, r := range p.rows {
+		for i, col := range r {
+			// 按原文计算宽度，高亮后再输出
+			text := col
+			if n > 0 || !withHeader {
+				text = p.highlightCol(i, col)
+			}
+			p.w.WriteString(text)
+			if i == len(r)-1 {
+				break
+			}
+			p.w.WriteString(strings.Repeat(" ", widths[i]-runewidth.StringWidth(col)+2))
+		}
+		p.w.WriteString("\n")
+	}
+	p.rows = p.rows[:0]
+}
+
+// highlightCol 第一列是 key，value 列总是最后一列
+func (p *printer) highlightCol(i int, col string) string {
+	switch {
+	case i == 0:
+		return highlight(col, p.keyHL)
+	case p.pv && i == len(p.rows[0])-1:
+		return highlight(col, p.valueHL)
+	}
+	return col
+}
+
+// finish 输出汇总并刷新缓冲，total 为扫描命令的结果总数
+func (p *printer) finish(total int) {
+	switch p.format {
+	case formatJSON:
+		if p.single {
+			break
+		}
+		if !p.started {
+			p.w.WriteString(`{"items":[`)
+		}
+		fmt.Fprintf(p.w, "\n],\"total\":%d}\n", total)
+	case formatCSV:
+		p.csv.Flush()
+	case formatTable:
+		p.flushTable()
+		if !p.single {
+			fmt.Fprintln(p.w, "-------------------")
+			fmt.Fprintf(p.w, "total: %d\n", total)
+		}
+	}
+	p.w.Flush()
+}
+
+// printTotal 输出计数结果
+func (p *printer) printTotal(total int) {
+	switch p.format {
+	case formatJSON, formatJSONL:
+		fmt.Fprintf(p.w, "{\"total\":%d}\n", total)
+	case formatCSV:
+		fmt.Fprintf(p.w, "total\n%d\n", total)
+	case formatRaw:
+		fmt.Fprintln(p.w, total)
+	default:
+		fmt.Fprintln(p.w, "Total: ", total)
+	}
+	p.w.Flush()
+}
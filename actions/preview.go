@@ -0,0 +1,123 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+const defaultPreviewShown = 10 // -dry-run 默认在两端各列出的 key 数
+
+// deletePreview -dry-run 的统计：会删除的 key 数和 key、value 的总字节数，以及最前和最后的若干个 key
+type deletePreview struct {
+	show    int
+	total   int
+	bytes   int64
+	first   [][]byte
+	last    [][]byte // 环形缓冲，next 是下一个写入的位置
+	next    int
+	skipped int // value 不是锁记录而跳过的 key
+}
+
+func (p *deletePreview) add(key, value []byte) {
+	p.total++
+	p.bytes += int64(len(key) + len(value))
+	if len(p.first) < p.show {
+		p.first = append(p.first, key)
+	}
+	if p.show == 0 {
+		return
+	}
+	if len(p.last) < p.show {
+		p.last = append(p.last, key)
+		return
+	}
+	p.last[p.next] = key
+	p.next = (p.next + 1) % p.show
+}
+
+// tail 最后的 key 中不在 first 里的部分，按 key 顺序
+func (p *deletePreview) tail() [][]byte {
+	keys := append(append([][]byte{}, p.last[p.next:]...), p.last[:p.next]...)
+	if n := p.total - len(p.first); n < len(keys) {
+		keys = keys[len(keys)-n:]
+	}
+	return keys
+}
+
+// rangeText -dry-run 报告的扫描范围，没有上界时写作 end of keyspace
+func (c *TiKVClient) rangeText(t scanTask) string {
+	end := "end of keyspace"
+	if len(t.end) > 0 {
+		end = c.fmtKey(t.end)
+	}
+	return fmt.Sprintf("[%s, %s)", c.fmtKey(t.start), end)
+}
+
+// previewDelete -dry-run：用只读快照按 key 顺序扫描 t 的范围，统计会被删除的 key，不开启写事务。
+// match 不为 nil 时在 t.filter 之外再按它过滤，返回错误的 key 计为跳过；limit > 0 时只统计前 limit 个
+func (c *TiKVClient) previewDelete(t scanTask, limit, show int, match func(value []byte) (bool, error)) {
+	if show < 0 {
+		c.usage("-show must not be negative")
+		return
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+
+	t.ordered = true
+	p := &deletePreview{show: show}
+	err := c.scan(context.Background(), t, func(key, value []byte) bool {
+		select {
+		case <-sigCh:
+			c.status = ExitCancelled
+			return false
+		default:
+		}
+		if match != nil {
+			ok, err := match(value)
+			if err != nil {
+				p.skipped++
+			}
+			if !ok {
+				return true
+			}
+		}
+		p.add(key, value)
+		return limit <= 0 || p.total < limit
+	})
+	t.filter.reportSkipped(c.fmtKey)
+	if err != nil {
+		fmt.Printf("operation failed: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	if c.status == ExitCancelled {
+		fmt.Println("\noperation cancelled")
+		return
+	}
+
+	fmt.Println("dry run, nothing deleted")
+	fmt.Println("range:", c.rangeText(t))
+	if p.skipped > 0 {
+		fmt.Printf("skipped %d key(s) whose value is not a lock record\n", p.skipped)
+	}
+	fmt.Printf("would delete: %d keys, %d bytes\n", p.total, p.bytes)
+	tail := p.tail()
+	if len(tail) == 0 {
+		for _, k := range p.first {
+			fmt.Println(" ", c.fmtKey(k))
+		}
+		return
+	}
+	fmt.Printf("first %d:\n", len(p.first))
+	for _, k := range p.first {
+		fmt.Println(" ", c.fmtKey(k))
+	}
+	fmt.Printf("last %d:\n", len(tail))
+	for _, k := range tail {
+		fmt.Println(" ", c.fmtKey(k))
+	}
+}
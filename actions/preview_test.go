@@ -0,0 +1,65 @@
+package actions
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDeletePreview(t *testing.T) {
+	tests := []struct {
+		n, show     int
+		first, tail []string
+	}{
+		{0, 3, nil, nil},
+		{2, 3, []string{"k0", "k1"}, nil},
+		{3, 3, []string{"k0", "k1", "k2"}, nil},
+		{5, 3, []string{"k0", "k1", "k2"}, []string{"k3", "k4"}},
+		{6, 3, []string{"k0", "k1", "k2"}, []string{"k3", "k4", "k5"}},
+		{10, 3, []string{"k0", "k1", "k2"}, []string{"k7", "k8", "k9"}},
+		{5, 0, nil, nil},
+	}
+	for _, tt := range tests {
+		p := &deletePreview{show: tt.show}
+		for i := 0; i < tt.n; i++ {
+			p.add([]byte(fmt.Sprintf("k%d", i)), []byte("vv"))
+		}
+		var first, tail []string
+		for _, k := range p.first {
+			first = append(first, string(k))
+		}
+		for _, k := range p.tail() {
+			tail = append(tail, string(k))
+		}
+		if p.total != tt.n || p.bytes != int64(tt.n*4) {
+			t.Errorf("%d keys, -show=%d: total %d, %d bytes", tt.n, tt.show, p.total, p.bytes)
+		}
+		if !reflect.DeepEqual(first, tt.first) || !reflect.DeepEqual(tail, tt.tail) {
+			t.Errorf("%d keys, -show=%d: first %q last %q, want %q %q", tt.n, tt.show, first, tail, tt.first, tt.tail)
+		}
+	}
+}
+
+// del -dry-run 报告的范围与实际删除的范围一致
+func TestDeletePreviewRange(t *testing.T) {
+	c := &TiKVClient{}
+	bounds := func(start, end string) scanTask {
+		s, e := delRangeBounds([]byte(start), []byte(end))
+		return scanTask{start: s, end: e}
+	}
+	tests := []struct {
+		t    scanTask
+		want string
+	}{
+		{scanTask{start: []byte("k"), end: []byte("k\x00")}, `[k, k\x00)`},
+		{bounds("OS/1", "OS/9"), "[OS/1, OS/:)"},
+		{bounds("OS/1", "OS\xff"), "[OS/1, OT)"},
+		{bounds("OS/1", "\xff"), "[OS/1, end of keyspace)"},
+		{scanTask{start: []byte("OS/T03/Data/Lock"), end: rangeEnd([]byte("OS/T03/Data/Lock"), nil)}, "[OS/T03/Data/Lock, OS/T03/Data/Locl)"},
+	}
+	for _, tt := range tests {
+		if got := c.rangeText(tt.t); got != tt.want {
+			t.Errorf("rangeText(%q, %q) = %s, want %s", tt.t.start, tt.t.end, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"tikv/utils"
+	"time"
+)
+
+type purgeOptions struct {
+	parallel       int
+	allowProtected bool
+	confirm        []byte // -confirm 给出的前缀，nil 时交互输入
+}
+
+// handlePurge 用 DeleteRange 直接删除前缀下的所有 key 及其全部 MVCC 版本，按 region 并发执行，
+// 不经过事务，不写入删除的值，无法 undo。只有 admin profile 可以使用
+func (c *TiKVClient) handlePurge(prefix []byte, o purgeOptions) {
+	if c.Profile == nil || !c.Profile.Admin {
+		fmt.Println("purge is an admin command, set 'admin: true' in the profile to enable it")
+		c.status = ExitError
+		return
+	}
+	if len(prefix) == 0 {
+		c.usage("purge needs a non-empty prefix")
+		return
+	}
+	end := utils.PrefixEnd(prefix)
+	if end == nil {
+		c.usage("the prefix has no upper bound, purge a shorter range")
+		return
+	}
+	rng := fmt.Sprintf("[%s, %s)", c.fmtKey(prefix), c.fmtKey(end))
+
+	if hit := protectedOverlaps(c.Profile.Protected, prefix, end); len(hit) > 0 {
+		if !o.allowProtected {
+			fmt.Printf("range %s overlaps protected prefixes: %s\n", rng, strings.Join(hit, ", "))
+			fmt.Println("add -allow-protected to purge it anyway")
+			c.status = ExitError
+			return
+		}
+		fmt.Printf("warning: range %s overlaps protected prefixes: %s\n", rng, strings.Join(hit, ", "))
+	}
+
+	fmt.Printf("purge deletes every key in %s with all of its versions, outside any transaction.\n", rng)
+	fmt.Println("the values are not logged, it cannot be undone and earlier snapshots will not see the keys either.")
+	if !c.confirmPrefix(prefix, o.confirm) {
+		return
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-sigCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// 执行前先记录，中途失败或中断时日志中也有这次 purge
+	if err := c.writeLog(&utils.AuditRecord{Action: actionPurge, Key: b64(prefix), End: b64(end), Result: "started"}); err != nil {
+		fmt.Printf("write audit log err: %v, nothing was purged\n", err)
+		c.status = ExitError
+		return
+	}
+	if err := c.syncLog(); err != nil {
+		fmt.Printf("sync audit log err: %v, nothing was purged\n", err)
+		c.status = ExitError
+		return
+	}
+	startTime := time.Now()
+	regions, err := c.Client.DeleteRange(ctx, prefix, end, o.parallel)
+	if err != nil {
+		if ctx.Err() != nil {
+			fmt.Println("\noperation cancelled, part of the range may already be purged")
+			c.status = ExitCancelled
+			return
+		}
+		fmt.Printf("operation failed: %v, part of the range may already be purged\n", err)
+		c.status = ExitError
+		return
+	}
+	fmt.Printf("purged %s: %d regions, time consuming: %v\n", rng, regions, time.Since(startTime))
+}
+
+// confirmPrefix 要求输入与 prefix 完全相同的前缀确认，非交互时用 -confirm 给出；-y 不能代替
+func (c *TiKVClient) confirmPrefix(prefix, given []byte) bool {
+	if given == nil {
+		if c.AssumeYes {
+			fmt.Println("-y does not confirm a purge, pass -confirm=<prefix>")
+			c.status = ExitCancelled
+			return false
+		}
+		fmt.Println("Type the prefix to confirm: ")
+		var answer string
+		if _, err := fmt.Scan(&answer); err != nil {
+			fmt.Printf("input err: %v\n", err)
+			c.status = ExitCancelled
+			return false
+		}
+		toks, err := utils.Tokenize(answer)
+		if err == nil && len(toks) == 1 {
+			given, _ = utils.ParseLiteral(toks[0])
+		}
+	}
+	if given == nil || !bytes.Equal(given, prefix) {
+		fmt.Println("prefix does not match, nothing purged")
+		c.status = ExitCancelled
+		return false
+	}
+	return true
+}
+
+// protectedOverlaps 与 [start, end) 有交集的受保护前缀，end 为 nil 表示不设上界
+func protectedOverlaps(protected []string, start, end []byte) []string {
+	var hit []string
+	for _, p := range protected {
+		pEnd := utils.PrefixEnd([]byte(p))
+		if (pEnd == nil || bytes.Compare(start, pEnd) < 0) && (end == nil || bytes.Compare([]byte(p), end) < 0) {
+			hit = append(hit, p)
+		}
+	}
+	return hit
+}
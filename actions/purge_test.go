@@ -0,0 +1,35 @@
+package actions
+
+import (
+	"reflect"
+	"testing"
+	"tikv/utils"
+)
+
+func TestProtectedOverlaps(t *testing.T) {
+	protected := []string{"OS/T01/", "OS/T03/Data/", "\xff\xff"}
+	tests := []struct {
+		prefix string
+		want   []string
+	}{
+		{"OS/T02/", nil},
+		{"OS/T01/", []string{"OS/T01/"}},
+		{"OS/T01/Data/Lock", []string{"OS/T01/"}},
+		{"OS/", []string{"OS/T01/", "OS/T03/Data/"}},
+		{"OS/T03/", []string{"OS/T03/Data/"}},
+		{"OS/T03/Meta/", nil},
+		{"OS/T03/Data", []string{"OS/T03/Data/"}},
+		{"OS/T0", []string{"OS/T01/", "OS/T03/Data/"}},
+		// 上界 OS/T01 不包含 OS/T01/ 开头的 key
+		{"OS/T00", nil},
+		// 全为 0xff 的前缀没有上界
+		{"\xff", []string{"\xff\xff"}},
+		{"\xfe", nil},
+	}
+	for _, tt := range tests {
+		start := []byte(tt.prefix)
+		if got := protectedOverlaps(protected, start, utils.PrefixEnd(start)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("protectedOverlaps(%q) = %q, want %q", tt.prefix, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+package actions
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"tikv/utils"
+)
+
+// flagKind 选项值的类型
+type flagKind int
+
+const (
+	boolFlag flagKind = iota
+	intFlag
+	stringFlag
+	keyFlag // 取值按 key 字面量解析，支持 x'..'、b64:、"\x00"
+)
+
+type flagSpec struct {
+	name    string
+	kind    flagKind
+	value   string   // 帮助中显示的取值占位符，如 n、xxx
+	choices []string // 非空时取值只能是其中之一
+	usage   string
+	// noDefault 不取 profile 中的默认值，如 export 的 -o 是文件名而不是输出格式
+	noDefault bool
+}
+
+type argSpec struct {
+	name     string
+	optional bool
+	rest     bool // 取该位置起的剩余输入原文，只能用于最后一个参数
+	variadic bool // 可以给出多个，只能用于最后一个参数
+}
+
+// command 一条命令的声明：位置参数、选项、用法和示例
+type command struct {
+	name     string
+	synopsis []string // 用法行，多种形式时逐行列出
+	summary  string
+	args     []argSpec
+	flags    []flagSpec
+	examples []string
+	run      func(c *TiKVClient, in *input)
+	writes   bool     // 会修改数据，结束时在审计日志中记录结果
+	readOnly []string // writes 的命令中不修改数据的子命令，如 locks expired
+	// subFlags 按第一个参数选择的子命令各自可用的选项，为空时所有选项对整条命令可用
+	subFlags map[string][]string
+}
+
+// allows 子命令 sub 是否可以用选项 name
+func (cmd *command) allows(sub, name string) bool {
+	names, ok := cmd.subFlags[sub]
+	if !ok {
+		return true
+	}
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// modifies 这次执行是否会修改数据，-dry-run 不修改
+func (cmd *command) modifies(in *input) bool {
+	if in.bool("dry-run") {
+		return false
+	}
+	for _, sub := range cmd.readOnly {
+		if in.arg(0) == sub {
+			return false
+		}
+	}
+	return cmd.writes
+}
+
+// input 解析后的命令参数
+type input struct {
+	cmd   *command
+	args  []string
+	raw   []utils.Token // 与 args 一一对应的原始 token
+	flags map[string]string
+	keys  map[string][]byte // keyFlag 选项解析后的字节
+}
+
+var registry = map[string]*command{}
+
+func register(cmds ...*command) {
+	for _, cmd := range cmds {
+		registry[cmd.name] = cmd
+	}
+}
+
+func lookupCommand(name string) (*command, bool) {
+	cmd, ok := registry[name]
+	return cmd, ok
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (cmd *command) flag(name string) (flagSpec, bool) {
+	for _, f := range cmd.flags {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return flagSpec{}, false
+}
+
+// check 校验选项值与类型是否匹配
+func (f flagSpec) check(value string) error {
+	var err error
+	switch f.kind {
+	case boolFlag:
+		_, err = strconv.ParseBool(value)
+	case intFlag:
+		_, err = strconv.Atoi(value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for -%s: %s", f.name, value)
+	}
+	if len(f.choices) > 0 {
+		for _, choice := range f.choices {
+			if value == choice {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value for -%s: %s, use one of %s", f.name, value, strings.Join(f.choices, "|"))
+	}
+	return nil
+}
+
+// usageLine 单行用法，用于参数错误时的提示
+func (cmd *command) usageLine() string {
+	return "usage: " + strings.Join(cmd.synopsis, "; ")
+}
+
+// parse 按声明解析参数。以 - 开头且后跟字母的 token 视为选项（"-x" 加引号时不算），
+// 选项值写作 -limit=10 或 -limit 10；-- 之后的 token 全部作为位置参数。
+// rest 参数从它的位置起取剩余的全部输入，其中以 - 开头的 token 也不再当作选项，
+// 如 set k -abc 写入 "-abc"，选项要写在它之前。
+// 有子命令的命令只接受该子命令可用的选项。命令行未给出的选项取 defaults 中的值
+func (cmd *command) parse(cl *utils.CommandLine, defaults map[string]string) (*input, error) {
+	in := &input{cmd: cmd, flags: map[string]string{}, keys: map[string][]byte{}}
+	tokens := cl.Tokens[1:]
+	positionalOnly := false
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if !positionalOnly && tok.Raw == "--" {
+			positionalOnly = true
+			continue
+		}
+		n := len(in.args)
+		restNext := n < len(cmd.args) && cmd.args[n].rest
+		if positionalOnly || restNext || !isFlagToken(tok.Raw) {
+			if restNext {
+				if rest := tokens[i:]; len(rest) > 1 {
+					// 多个参数原样拼接，不再按字面量解析
+					span := restOfLine(cl.Line, rest)
+					tok = utils.Token{Text: span, Raw: span, Start: rest[0].Start, End: rest[len(rest)-1].End, Quoted: true}
+				} else if !tok.Enclosed {
+					tok.Text = dropContinuations(tok.Raw)
+				}
+				in.args = append(in.args, tok.Text)
+				in.raw = append(in.raw, tok)
+				break
+			}
+			in.args = append(in.args, tok.Text)
+			in.raw = append(in.raw, tok)
+			continue
+		}
+		name := strings.TrimLeft(tok.Text, "-")
+		value, hasValue := "", false
+		valueTok := tok
+		if idx := strings.Index(name, "="); idx >= 0 {
+			name, value, hasValue = name[:idx], name[idx+1:], true
+			valueTok = flagValueToken(tok)
+		}
+		spec, ok := cmd.flag(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown flag -%s for %s", name, cmd.name)
+		}
+		if !hasValue {
+			if spec.kind == boolFlag {
+				value = "true"
+			} else if i+1 < len(tokens) {
+				i++
+				value, valueTok = tokens[i].Text, tokens[i]
+			} else {
+				return nil, fmt.Errorf("flag -%s requires a value", name)
+			}
+		}
+		if err := spec.check(value); err != nil {
+			return nil, err
+		}
+		if spec.kind == keyFlag {
+			key, err := utils.ParseLiteral(valueTok)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for -%s: %v", name, err)
+			}
+			in.keys[name] = key
+		}
+		in.flags[name] = value
+	}
+
+	sub := in.arg(0)
+	for name := range in.flags {
+		if !cmd.allows(sub, name) {
+			return nil, fmt.Errorf("flag -%s does not apply to %s %s", name, cmd.name, sub)
+		}
+	}
+	for _, spec := range cmd.flags {
+		value, ok := defaults[spec.name]
+		if !ok || in.has(spec.name) || spec.noDefault || !cmd.allows(sub, spec.name) {
+			continue
+		}
+		if err := spec.check(value); err != nil {
+			return nil, fmt.Errorf("profile default: %v", err)
+		}
+		if spec.kind == keyFlag {
+			// 与命令行中的写法相同，x'..'、b64:、"\x00" 都按字面量解析
+			key, err := parseKeyText(value)
+			if err != nil {
+				return nil, fmt.Errorf("profile default: invalid value for -%s: %v", spec.name, err)
+			}
+			in.keys[spec.name] = key
+		}
+		in.flags[spec.name] = value
+	}
+
+	required := 0
+	for _, a := range cmd.args {
+		if !a.optional {
+			required++
+		}
+	}
+	if len(in.args) < required {
+		return nil, fmt.Errorf("%s: missing argument", cmd.name)
+	}
+	if len(in.args) > len(cmd.args) && (len(cmd.args) == 0 || !cmd.args[len(cmd.args)-1].variadic) {
+		return nil, fmt.Errorf("%s: too many arguments", cmd.name)
+	}
+	return in, nil
+}
+
+// parseKeyText 把一段文本当作一个命令行参数解析为 key
+func parseKeyText(s string) ([]byte, error) {
+	toks, err := utils.Tokenize(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) != 1 {
+		return nil, fmt.Errorf("%q is not a single key, quote it", s)
+	}
+	return utils.ParseLiteral(toks[0])
+}
+
+// restOfLine 剩余输入的原文，保留其中的空白和引号，去掉续行符；
+// heredoc 取其内容。只有一个整体加引号的参数或 heredoc 时取去掉引号后的内容
+func restOfLine(line string, tokens []utils.Token) string {
+	if len(tokens) == 1 && tokens[0].Enclosed {
+		return tokens[0].Text
+	}
+	var b strings.Builder
+	for i, tok := range tokens {
+		if i > 0 {
+			b.WriteString(line[tokens[i-1].End:tok.Start])
+		}
+		if tok.Enclosed && strings.HasPrefix(tok.Raw, "<<") {
+			b.WriteString(tok.Text)
+			continue
+		}
+		b.WriteString(dropContinuations(tok.Raw))
+	}
+	return b.String()
+}
+
+// dropContinuations 去掉引号外的反斜杠换行
+func dropContinuations(raw string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			} else if ch == '\\' && quote == '"' && i+1 < len(raw) {
+				b.WriteByte(ch)
+				i++
+				ch = raw[i]
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '\\' && i+1 < len(raw):
+			if raw[i+1] == '\n' {
+				i++
+				continue
+			}
+			b.WriteByte(ch)
+			i++
+			ch = raw[i]
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// flagValueToken 取 -name=value 中 = 之后的部分，按原始写法重新拆分，
+// 以便 -after="OS\x00" 与单独的参数一样解析字面量
+func flagValueToken(tok utils.Token) utils.Token {
+	raw := tok.Raw[strings.Index(tok.Raw, "=")+1:]
+	if toks, err := utils.Tokenize(raw); err == nil && len(toks) == 1 && toks[0].Start == 0 && toks[0].End == len(raw) {
+		return toks[0]
+	}
+	return utils.Token{Text: raw, Raw: raw}
+}
+
+func isFlagToken(tok string) bool {
+	name := strings.TrimLeft(tok, "-")
+	if name == tok || name == "" || len(tok)-len(name) > 2 {
+		return false
+	}
+	ch := name[0]
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+// arg 第 i 个位置参数，不存在时返回空串
+func (in *input) arg(i int) string {
+	if i < len(in.args) {
+		return in.args[i]
+	}
+	return ""
+}
+
+// bytes 第 i 个位置参数按 x'..'、b64:、"\x00" 等字面量解析后的字节
+func (in *input) bytes(i int) ([]byte, error) {
+	if i >= len(in.raw) {
+		return nil, nil
+	}
+	return utils.ParseLiteral(in.raw[i])
+}
+
+func (in *input) has(name string) bool {
+	_, ok := in.flags[name]
+	return ok
+}
+
+func (in *input) bool(name string) bool {
+	b, _ := strconv.ParseBool(in.flags[name])
+	return b
+}
+
+// int 读取整数选项，未指定时返回 def
+func (in *input) int(name string, def int) int {
+	v, ok := in.flags[name]
+	if !ok {
+		return def
+	}
+	n, _ := strconv.Atoi(v)
+	return n
+}
+
+func (in *input) str(name string) string {
+	return in.flags[name]
+}
+
+// key keyFlag 选项的字节值
+func (in *input) key(name string) []byte {
+	return in.keys[name]
+}
+
+// printHelp help 命令：不带参数列出全部命令，带参数打印该命令的详细用法
+func printHelp(name string) bool {
+	if name == "" {
+		fmt.Println("commands:")
+		for _, n := range commandNames() {
+			fmt.Printf("  %-8s %s\n", n, registry[n].summary)
+		}
+		fmt.Println("type 'help <command>' for details")
+		fmt.Println("every token after -- is an argument even if it starts with -, e.g. del -- -tmp/key")
+		return true
+	}
+
+	cmd, ok := lookupCommand(name)
+	if !ok {
+		fmt.Printf("unknown command: %s\n", name)
+		return false
+	}
+	fmt.Println(cmd.summary)
+	fmt.Println("usage:")
+	for _, s := range cmd.synopsis {
+		fmt.Printf("  %s\n", s)
+	}
+	if len(cmd.flags) > 0 {
+		fmt.Println("flags:")
+		for _, f := range cmd.flags {
+			flag := "-" + f.name
+			if f.kind != boolFlag {
+				flag += "=" + f.value
+			}
+			fmt.Printf("  %-16s %s\n", flag, f.usage)
+		}
+	}
+	if len(cmd.examples) > 0 {
+		fmt.Println("examples:")
+		for _, e := range cmd.examples {
+			fmt.Printf("  %s\n", e)
+		}
+	}
+	return true
+}
@@ -0,0 +1,178 @@
+package actions
+
+import (
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"tikv/utils"
+)
+
+func TestParseRestArg(t *testing.T) {
+	set, _ := lookupCommand("set")
+	del, _ := lookupCommand("del")
+	tests := []struct {
+		cmd   *command
+		line  string
+		args  []string
+		flags map[string]string
+	}{
+		{set, "set k -abc", []string{"k", "-abc"}, map[string]string{}},
+		{set, "set k -1 x", []string{"k", "-1 x"}, map[string]string{}},
+		{set, "set k -- -abc", []string{"k", "-abc"}, map[string]string{}},
+		{set, `set k "-abc"`, []string{"k", "-abc"}, map[string]string{}},
+		{del, "del -nolog k", []string{"k"}, map[string]string{"nolog": "true"}},
+		{del, "del -- -k", []string{"-k"}, map[string]string{}},
+	}
+	for _, tt := range tests {
+		cl, err := utils.ParseCommandLine(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := tt.cmd.parse(cl, nil)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(in.args, tt.args) || !reflect.DeepEqual(in.flags, tt.flags) {
+			t.Errorf("parse(%q) = %q %v, want %q %v", tt.line, in.args, in.flags, tt.args, tt.flags)
+		}
+	}
+}
+
+// 多个参数时 heredoc 取其内容，续行符不进入值
+func TestParseRestArgMultiline(t *testing.T) {
+	set, _ := lookupCommand("set")
+	tests := []struct {
+		lines []string
+		want  string
+	}{
+		{[]string{"set k abc <<EOF", "line 1", "line 2", "EOF"}, "abc line 1\nline 2"},
+		{[]string{"set k <<EOF", "{\"a\": 1}", "EOF"}, "{\"a\": 1}"},
+		{[]string{"set k a\\", "b"}, "ab"},
+		{[]string{"set k a \\", "b"}, "a b"},
+		{[]string{`set k 'x \`, `y' z`}, "'x \\\ny' z"},
+	}
+	for _, tt := range tests {
+		rest := tt.lines[1:]
+		cl, err := utils.ReadCommand(tt.lines[0], func() (string, error) {
+			if len(rest) == 0 {
+				return "", io.EOF
+			}
+			line := rest[0]
+			rest = rest[1:]
+			return line, nil
+		})
+		if err != nil {
+			t.Fatalf("ReadCommand(%q): %v", tt.lines, err)
+		}
+		in, err := set.parse(cl, nil)
+		if err != nil {
+			t.Errorf("parse(%q): %v", tt.lines, err)
+			continue
+		}
+		if got := in.arg(1); got != tt.want {
+			t.Errorf("parse(%q) value = %q, want %q", tt.lines, got, tt.want)
+		}
+	}
+}
+
+func TestParseUnknownFlag(t *testing.T) {
+	del, _ := lookupCommand("del")
+	cl, _ := utils.ParseCommandLine("del k -abc")
+	if _, err := del.parse(cl, nil); err == nil {
+		t.Error("del k -abc should report an unknown flag")
+	}
+}
+
+// profile 中的默认值不能替用户打开 -nolog、-force 这类选项
+func TestParseNoDefault(t *testing.T) {
+	defaults := map[string]string{"nolog": "true", "force": "true", "kfmt": "hex"}
+	for _, line := range []string{"del k", "undo 20250701-100000-3fa1"} {
+		cl, _ := utils.ParseCommandLine(line)
+		cmd, _ := lookupCommand(cl.Args()[0])
+		in, err := cmd.parse(cl, defaults)
+		if err != nil {
+			t.Fatalf("parse(%q): %v", line, err)
+		}
+		if in.has("nolog") || in.has("force") {
+			t.Errorf("parse(%q) took -nolog or -force from the defaults: %v", line, in.flags)
+		}
+	}
+	cl, _ := utils.ParseCommandLine("del k")
+	del, _ := lookupCommand("del")
+	if in, _ := del.parse(cl, defaults); in.str("kfmt") != "hex" {
+		t.Errorf("del k -kfmt = %q, want the profile default hex", in.str("kfmt"))
+	}
+}
+
+// locks 的各个子命令只接受自己用得到的选项，profile 中的默认值也只取用得到的
+func TestParseSubcommandFlags(t *testing.T) {
+	locks, _ := lookupCommand("locks")
+	defaults := map[string]string{"o": "json", "kfmt": "hex", "vfmt": "hex"}
+	tests := []struct {
+		line string
+		ok   bool
+	}{
+		{"locks expired OS/T03 -at=\"2025-07-08 00:00:00\" -limit=10 -o json", true},
+		{"locks reap OS/T03 -dry-run -show=5 -rate=100 -nolog", true},
+		{"locks stats OS/T03 -older=7d -o json", true},
+		{"locks expired OS/T03 -dry-run", false},
+		{"locks expired OS/T03 -rate=100", false},
+		{"locks expired OS/T03 -nolog", false},
+		{"locks stats OS/T03 -limit=10", false},
+		{"locks stats OS/T03 -pause=1s", false},
+		{"locks stats OS/T03 -bytes-rate=1MB", false},
+		{"locks reap OS/T03 -at=\"2025-07-08 00:00:00\"", false},
+		{"locks reap OS/T03 -o json", false},
+	}
+	for _, tt := range tests {
+		cl, err := utils.ParseCommandLine(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := locks.parse(cl, defaults)
+		if (err == nil) != tt.ok {
+			t.Errorf("parse(%q) err = %v, want ok = %v", tt.line, err, tt.ok)
+			continue
+		}
+		if err == nil && in.arg(0) == "reap" && in.has("o") {
+			t.Errorf("parse(%q) took -o from the defaults", tt.line)
+		}
+		if err == nil && in.arg(0) == "stats" && in.has("vfmt") {
+			t.Errorf("parse(%q) took -vfmt from the defaults", tt.line)
+		}
+	}
+}
+
+// profile 中 keyFlag 的默认值与命令行中的同样写法表示同一个 key
+func TestParseKeyFlagDefault(t *testing.T) {
+	ll, _ := lookupCommand("ll")
+	tests := []struct {
+		def  string
+		want string
+		ok   bool
+	}{
+		{"OS/T03/a", "OS/T03/a", true},
+		{"x'4f53'", "OS", true},
+		{"b64:T1M=", "OS", true},
+		{`"OS\x00"`, "OS\x00", true},
+		{"x'4f5'", "", false},
+		{"a b", "", false},
+		{`"OS`, "", false},
+	}
+	for _, tt := range tests {
+		cl, _ := utils.ParseCommandLine("ll OS/")
+		in, err := ll.parse(cl, map[string]string{"after": tt.def})
+		if (err == nil) != tt.ok {
+			t.Errorf("default -after=%s: err = %v, want ok = %v", tt.def, err, tt.ok)
+			continue
+		}
+		if err == nil && string(in.keys["after"]) != tt.want {
+			t.Errorf("default -after=%s = %q, want %q", tt.def, in.keys["after"], tt.want)
+		}
+		if err != nil && !strings.HasPrefix(err.Error(), "profile default") {
+			t.Errorf("default -after=%s: err = %v, want a profile default error", tt.def, err)
+		}
+	}
+}
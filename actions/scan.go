@@ -0,0 +1,326 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"github.com/tikv/client-go/v2/tikv"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+	"sync"
+)
+
+// kvIter 与 client-go 返回的迭代器方法一致
+type kvIter interface {
+	Valid() bool
+	Key() []byte
+	Value() []byte
+	Next() error
+	Close()
+}
+
+// lowerBoundIter IterReverse 没有下界，遍历到小于 lower 的 key 时结束
+type lowerBoundIter struct {
+	kvIter
+	lower []byte
+}
+
+func (it *lowerBoundIter) Valid() bool {
+	return it.kvIter.Valid() && bytes.Compare(it.Key(), it.lower) >= 0
+}
+
+// openIter 打开 [start, end) 上的迭代器，reverse 时从 end 之前的最后一个 key 向前遍历
+func openIter(snap *txnsnapshot.KVSnapshot, start, end []byte, reverse bool) (kvIter, error) {
+	if !reverse {
+		iter, err := snap.Iter(start, end)
+		return iter, err
+	}
+	iter, err := snap.IterReverse(end)
+	if err != nil {
+		return nil, err
+	}
+	return &lowerBoundIter{kvIter: iter, lower: start}, nil
+}
+
+const (
+	defaultParallel = 4   // 默认同时扫描的 region 数
+	scanBatchRows   = 256 // worker 每次交给调用方的记录数
+	scanChunkAhead  = 4   // 按序输出时每段最多缓存的批数
+	locateBackoffMS = 20000
+)
+
+type kvPair struct {
+	key, value []byte
+}
+
+type keyRange struct {
+	start, end []byte
+}
+
+// scanTask 一次扫描：范围、方向和并发度由命令参数决定，顺序和过滤由具体命令决定
+type scanTask struct {
+	start, end []byte
+	reverse    bool
+	parallel   int
+	ordered    bool       // 按 key 顺序交给调用方，否则按各段完成的先后
+	keyOnly    bool       // 不需要 value
+	ts         uint64     // 读取的版本，0 表示当前时间
+	filter     *keyFilter // 在 worker 中过滤，nil 表示全部
+}
+
+// splitByRegion 按 region cache 中的边界把 [start, end) 切成若干段，end 为空表示不设上界
+func (c *TiKVClient) splitByRegion(ctx context.Context, start, end []byte) ([]keyRange, error) {
+	if len(end) > 0 && bytes.Compare(start, end) >= 0 {
+		return nil, nil
+	}
+	bo := tikv.NewBackofferWithVars(ctx, locateBackoffMS, nil)
+	cache := c.Client.GetRegionCache()
+	var ranges []keyRange
+	for key := start; ; {
+		loc, err := cache.LocateKey(bo, key)
+		if err != nil {
+			return nil, fmt.Errorf("locate region of %s err: %v", c.fmtKey(key), err)
+		}
+		if len(loc.EndKey) == 0 || len(end) > 0 && bytes.Compare(loc.EndKey, end) >= 0 {
+			return append(ranges, keyRange{start: key, end: end}), nil
+		}
+		ranges = append(ranges, keyRange{start: key, end: loc.EndKey})
+		key = loc.EndKey
+	}
+}
+
+// scan 按 region 切分 t 的范围，最多 t.parallel 段同时扫描，所有段读同一时间点的快照。
+// emit 在调用方的 goroutine 中逐条执行，返回 false 时停止扫描
+func (c *TiKVClient) scan(ctx context.Context, t scanTask, emit func(key, value []byte) bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ts := t.ts
+	if ts == 0 {
+		var err error
+		if ts, err = c.Client.GetTimestamp(ctx); err != nil {
+			return fmt.Errorf("get timestamp err: %v", err)
+		}
+	}
+	chunks, err := c.splitByRegion(ctx, t.start, t.end)
+	if err != nil {
+		return err
+	}
+	if t.reverse {
+		for i, j := 0, len(chunks)-1; i < j; i, j = i+1, j-1 {
+			chunks[i], chunks[j] = chunks[j], chunks[i]
+		}
+	}
+	parallel := t.parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	if parallel > len(chunks) {
+		parallel = len(chunks)
+	}
+
+	// 按序输出时每段一个通道，调用方依次读完；否则各段共用一个通道
+	outs := make([]chan []kvPair, len(chunks))
+	shared := make(chan []kvPair, parallel*scanChunkAhead)
+	for i := range outs {
+		if t.ordered {
+			outs[i] = make(chan []kvPair, scanChunkAhead)
+		} else {
+			outs[i] = shared
+		}
+	}
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	fail := func(err error) {
+		errOnce.Do(func() {
+			firstErr = err
+			cancel()
+		})
+	}
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range chunks {
+			select {
+			case next <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < parallel; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			snap := c.Client.GetSnapshot(ts)
+			snap.SetKeyOnly(t.keyOnly)
+			for i := range next {
+				err := scanChunk(ctx, snap, chunks[i], t, outs[i])
+				if t.ordered {
+					close(outs[i])
+				}
+				if err != nil {
+					fail(err)
+					return
+				}
+			}
+		}()
+	}
+	if !t.ordered {
+		go func() {
+			wg.Wait()
+			close(shared)
+		}()
+	}
+
+	// 出错时未分配的段不会关闭通道，需要同时等待 ctx
+	stopped := false
+	consume := func(out chan []kvPair) {
+		for {
+			select {
+			case batch, ok := <-out:
+				if !ok {
+					return
+				}
+				for _, kv := range batch {
+					if !emit(kv.key, kv.value) {
+						stopped = true
+						cancel()
+						return
+					}
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+	if t.ordered {
+		for i := 0; i < len(outs) && ctx.Err() == nil; i++ {
+			consume(outs[i])
+		}
+	} else {
+		consume(shared)
+	}
+	cancel()
+	wg.Wait()
+	if stopped {
+		return nil
+	}
+	return historyErr(firstErr, t.ts)
+}
+
+// scanChunk 扫描一段并按批发送到 out，ctx 取消时提前结束
+func scanChunk(ctx context.Context, snap *txnsnapshot.KVSnapshot, r keyRange, t scanTask, out chan<- []kvPair) error {
+	iter, err := openIter(snap, r.start, r.end, t.reverse)
+	if err != nil {
+		return fmt.Errorf("create iteration err: %v", err)
+	}
+	defer iter.Close()
+
+	send := func(batch []kvPair) bool {
+		select {
+		case out <- batch:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	// scanner 每次返回新的结果，key 和 value 不会被复用，无需拷贝
+	batch := make([]kvPair, 0, scanBatchRows)
+	for iter.Valid() {
+		if ctx.Err() != nil {
+			return nil
+		}
+		if t.filter == nil || t.filter.matches(iter.Key(), iter.Value()) {
+			batch = append(batch, kvPair{key: iter.Key(), value: iter.Value()})
+			if len(batch) == scanBatchRows {
+				if !send(batch) {
+					return nil
+				}
+				batch = make([]kvPair, 0, scanBatchRows)
+			}
+		}
+		if err := iter.Next(); err != nil {
+			return fmt.Errorf("iteration failed: %v", err)
+		}
+	}
+	if len(batch) > 0 {
+		send(batch)
+	}
+	return nil
+}
+
+// scanBounds 由 <prefixKey> [endKey] 得到扫描范围 [start, end)，
+// 再按 -from（包含）或 -after（不包含）收窄；reverse 时二者表示向前继续的位置
+func scanBounds(in *input, key1, key2 []byte, reverse bool) (start, end []byte) {
+	start, end = key1, rangeEnd(key1, key2)
+	var seek []byte
+	switch {
+	case in.has("after"):
+		seek = in.key("after")
+		if !reverse {
+			seek = append(append([]byte{}, seek...), 0)
+		}
+	case in.has("from"):
+		seek = in.key("from")
+		if reverse {
+			seek = append(append([]byte{}, seek...), 0)
+		}
+	default:
+		return start, end
+	}
+	if reverse {
+		if len(end) == 0 || bytes.Compare(seek, end) < 0 {
+			end = seek
+		}
+	} else if bytes.Compare(seek, start) > 0 {
+		start = seek
+	}
+	return start, end
+}
+
+// cursor 上一页 ll/find 的命令参数和输出的最后一个 key
+type cursor struct {
+	in      *input
+	lastKey []byte
+}
+
+// saveCursor 输出满 limit 条时记住位置，供 next 或直接回车继续；否则清除
+func (c *TiKVClient) saveCursor(in *input, p *printer) {
+	limit := in.int("limit", -1)
+	if c.status != ExitOK || limit <= 0 || p.count < limit {
+		c.cursor = nil
+		return
+	}
+	c.cursor = &cursor{in: in, lastKey: p.lastKey}
+	if p.format == formatTable {
+		fmt.Println("type 'next' or press Enter for more")
+	}
+}
+
+// handleNext 以相同的参数从上一页最后一个 key 之后继续
+func (c *TiKVClient) handleNext() {
+	if c.cursor == nil {
+		fmt.Println("nothing to continue, run ll or find with -limit first")
+		c.status = ExitError
+		return
+	}
+	prev := c.cursor.in
+	in := &input{cmd: prev.cmd, args: prev.args, raw: prev.raw, flags: map[string]string{}, keys: map[string][]byte{}}
+	for k, v := range prev.flags {
+		in.flags[k] = v
+	}
+	for k, v := range prev.keys {
+		in.keys[k] = v
+	}
+	delete(in.flags, "from")
+	in.flags["after"] = "x'" + hex.EncodeToString(c.cursor.lastKey) + "'"
+	in.keys["after"] = c.cursor.lastKey
+
+	c.kfmt, c.vfmt = in.str("kfmt"), in.str("vfmt")
+	in.cmd.run(c, in)
+}
@@ -0,0 +1,48 @@
+package actions
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRangeEnd(t *testing.T) {
+	tests := []struct {
+		start, end string
+		want       []byte
+	}{
+		{"OS/", "", []byte("OS0")},
+		{"OS/9", "", []byte("OS/:")},
+		{"OS\xff", "", []byte("OT")},
+		{"\xff\xff", "", nil},
+		{"", "", nil},
+		{"OS/1", "OS/9", []byte("OS/:")},
+		{"OS/1", "OS\xff", []byte("OT")},
+		{"OS/1", "\xff", nil},
+	}
+	for _, tt := range tests {
+		if got := rangeEnd([]byte(tt.start), []byte(tt.end)); !bytes.Equal(got, tt.want) || (got == nil) != (tt.want == nil) {
+			t.Errorf("rangeEnd(%q, %q) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestDelRangeBounds(t *testing.T) {
+	tests := []struct {
+		start, end string
+		wantEnd    []byte
+	}{
+		{"OS/1", "OS/9", []byte("OS/:")},
+		{"OS/1", "OS/99", []byte("OS/9:")},
+		{"OS\x00", "OS\xff", []byte("OT")},
+		{"\x00", "\xff\xff", nil},
+	}
+	for _, tt := range tests {
+		start, end := delRangeBounds([]byte(tt.start), []byte(tt.end))
+		if string(start) != tt.start || !bytes.Equal(end, tt.wantEnd) || (end == nil) != (tt.wantEnd == nil) {
+			t.Errorf("delRangeBounds(%q, %q) = %q, %q, want %q, %q", tt.start, tt.end, start, end, tt.start, tt.wantEnd)
+		}
+		if end != nil && bytes.Compare(start, end) >= 0 {
+			t.Errorf("delRangeBounds(%q, %q): empty range [%q, %q)", tt.start, tt.end, start, end)
+		}
+	}
+}
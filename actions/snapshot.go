@@ -0,0 +1,112 @@
+package actions
+
+import (
+	"context"
+	"fmt"
+	"github.com/tikv/client-go/v2/oracle"
+	"github.com/tikv/client-go/v2/txnkv/txnsnapshot"
+	"strconv"
+	"tikv/utils"
+	"time"
+)
+
+const timeLayout = "2006-01-02 15:04:05"
+
+// parseReadTS 解析时间 "2025-07-01 10:00:00"（按 profile 时区）或 TSO，
+// 不允许晚于当前时间
+func parseReadTS(s string, isTSO bool) (uint64, error) {
+	var ts uint64
+	if isTSO {
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid tso %s", s)
+		}
+		ts = n
+	} else {
+		if _, err := time.Parse(timeLayout, s); err != nil {
+			return 0, fmt.Errorf("invalid time %q, use \"%s\"", s, timeLayout)
+		}
+		ts = utils.TimeToTS(s)
+	}
+	if oracle.GetTimeFromTS(ts).After(time.Now()) {
+		return 0, fmt.Errorf("%s is in the future", utils.TikvTimeFormat(ts))
+	}
+	return ts, nil
+}
+
+// readTS 本次读取的版本：-at、-at-ts 优先，其次是 snapshot 命令固定的版本，0 表示读最新数据。
+// 修改数据的命令（包括其 -dry-run）按最新数据选择要删除的 key 并记录删除前的值，不使用固定的版本
+func (c *TiKVClient) readTS(in *input) (uint64, bool) {
+	if in.has("at") && in.has("at-ts") {
+		c.usage("use either -at or -at-ts")
+		return 0, false
+	}
+	var (
+		ts  uint64
+		err error
+	)
+	switch {
+	case in.has("at"):
+		ts, err = parseReadTS(in.str("at"), false)
+	case in.has("at-ts"):
+		ts, err = parseReadTS(in.str("at-ts"), true)
+	case in.cmd.writes:
+		if c.snapshotTS != 0 {
+			fmt.Printf("note: %s reads the latest data, not the snapshot pinned to %s\n", in.cmd.name, utils.TikvTimeFormat(c.snapshotTS))
+		}
+		return 0, true
+	default:
+		return c.snapshotTS, true
+	}
+	if err != nil {
+		fmt.Println(err)
+		c.status = ExitError
+		return 0, false
+	}
+	return ts, true
+}
+
+// snapshot 版本 ts 的只读快照，ts 为 0 时取当前时间
+func (c *TiKVClient) snapshot(ctx context.Context, ts uint64) (*txnsnapshot.KVSnapshot, error) {
+	if ts == 0 {
+		var err error
+		if ts, err = c.Client.GetTimestamp(ctx); err != nil {
+			return nil, fmt.Errorf("get timestamp err: %v", err)
+		}
+	}
+	return c.Client.GetSnapshot(ts), nil
+}
+
+// historyErr 读历史版本失败时补充说明，早于 GC safe point 的数据已被回收
+func historyErr(err error, ts uint64) error {
+	if err == nil || ts == 0 {
+		return err
+	}
+	return fmt.Errorf("%v (reading at %s, ts %d; versions older than the GC safe point are gone)", err, utils.TikvTimeFormat(ts), ts)
+}
+
+// handleSnapshot 固定之后所有读命令的版本，off 恢复读最新数据，不带参数时显示当前状态
+func (c *TiKVClient) handleSnapshot(arg string) {
+	switch arg {
+	case "":
+		if c.snapshotTS == 0 {
+			fmt.Println("snapshot off, reads see the latest data")
+			return
+		}
+		fmt.Printf("reads pinned to %s (ts %d)\n", utils.TikvTimeFormat(c.snapshotTS), c.snapshotTS)
+		return
+	case "off":
+		c.snapshotTS = 0
+		fmt.Println("snapshot off, reads see the latest data")
+		return
+	}
+	_, err := strconv.ParseUint(arg, 10, 64)
+	ts, err := parseReadTS(arg, err == nil)
+	if err != nil {
+		fmt.Println(err)
+		c.status = ExitError
+		return
+	}
+	c.snapshotTS = ts
+	fmt.Printf("reads pinned to %s (ts %d), 'snapshot off' to return to the latest data\n", utils.TikvTimeFormat(ts), ts)
+}
@@ -0,0 +1,34 @@
+package actions
+
+import (
+	"testing"
+	"tikv/utils"
+)
+
+// 固定 snapshot 后读命令使用固定的版本，删除命令及其 -dry-run 读最新数据
+func TestReadTSPinned(t *testing.T) {
+	c := &TiKVClient{snapshotTS: 459038124316229633}
+	tests := []struct {
+		line string
+		want uint64
+	}{
+		{"find OS/T03/ -value=C003", c.snapshotTS},
+		{"count OS/T03/", c.snapshotTS},
+		{"fd OS/T03/ -value=C003", 0},
+		{"fd OS/T03/ -value=C003 -dry-run", 0},
+	}
+	for _, tt := range tests {
+		cl, err := utils.ParseCommandLine(tt.line)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd, _ := lookupCommand(cl.Args()[0])
+		in, err := cmd.parse(cl, nil)
+		if err != nil {
+			t.Fatalf("parse(%q): %v", tt.line, err)
+		}
+		if ts, ok := c.readTS(in); !ok || ts != tt.want {
+			t.Errorf("readTS(%q) = %d, %v, want %d", tt.line, ts, ok, tt.want)
+		}
+	}
+}
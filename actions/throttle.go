@@ -0,0 +1,246 @@
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"tikv/utils"
+	"time"
+
+	tikverr "github.com/tikv/client-go/v2/error"
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+	"github.com/tikv/client-go/v2/util"
+)
+
+const (
+	busyBackoff     = 500 * time.Millisecond // 一次提交的 backoff 超过它就认为 TiKV 忙
+	busyRetries     = 5                      // TiKV 忙导致提交失败时一批最多重试的次数
+	minThrottleRate = 0.05                   // 自动降速最低降到设定速度的比例
+)
+
+// throttle 控制删除的速度：每秒 key 数、每秒字节数（key 与 value 之和）以及批之间的停顿，
+// 多个 goroutine 共用时合计不超过设定的速度。
+// 提交遇到 ServerIsBusy 或 backoff 变大时把速度减半，之后每批顺利提交逐步恢复；
+// 没有设定速度时以遇到忙时的实际速度为基准
+type throttle struct {
+	keysPerSec  float64
+	bytesPerSec float64
+	pause       time.Duration
+
+	mu     sync.Mutex
+	factor float64   // 当前速度占设定速度的比例
+	auto   bool      // keysPerSec 是遇到忙时按实际速度定的
+	start  time.Time // 第一批开始提交的时间，确认提示和扫描第一批之前的时间不计入速度
+	next   time.Time // 下一批最早可以提交的时间
+	keys   int
+	bytes  int64
+
+	// 最近一段时间的吞吐，随批次的进度一起打印
+	winStart time.Time
+	winKeys  int
+	winBytes int64
+	rate     string
+}
+
+// newThrottle 读取 -rate、-bytes-rate 和 -pause，都未给出时不限速，但仍在 TiKV 忙时自动降速。
+// 计时从第一批提交开始，在确认之前创建也不影响统计的速度
+func newThrottle(in *input) (*throttle, error) {
+	t := &throttle{factor: 1}
+	if in.has("rate") {
+		n := in.int("rate", 0)
+		if n < 1 {
+			return nil, fmt.Errorf("-rate must be at least 1 key per second")
+		}
+		t.keysPerSec = float64(n)
+	}
+	if s := in.str("bytes-rate"); s != "" {
+		n, err := utils.ParseSize(s)
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("-bytes-rate: invalid size %q, use e.g. 512KB or 8MB", s)
+		}
+		t.bytesPerSec = float64(n)
+	}
+	if s := in.str("pause"); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil || d < 0 {
+			return nil, fmt.Errorf("-pause: invalid duration %q, use e.g. 200ms or 1s", s)
+		}
+		t.pause = d
+	}
+	return t, nil
+}
+
+// batchSize 每批删除的 key 数，限速时不超过一秒的量，避免一批提交过大、停顿过长
+func (t *throttle) batchSize(def int) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.keysPerSec > 0 {
+		if n := int(t.keysPerSec * t.factor); n < def {
+			return max(n, 1)
+		}
+	}
+	return def
+}
+
+// wait 按当前速度为 keys 个、bytes 字节的一批预留提交的时间，并等到那时
+func (t *throttle) wait(keys int, bytes int64) {
+	t.mu.Lock()
+	var d time.Duration
+	if t.keysPerSec > 0 {
+		d = time.Duration(float64(keys) / (t.keysPerSec * t.factor) * float64(time.Second))
+	}
+	if t.bytesPerSec > 0 {
+		d = max(d, time.Duration(float64(bytes)/(t.bytesPerSec*t.factor)*float64(time.Second)))
+	}
+	now := time.Now()
+	if t.start.IsZero() {
+		t.start, t.winStart = now, now
+	}
+	at := t.next
+	if at.Before(now) {
+		at = now
+	}
+	t.next = at.Add(d)
+	t.mu.Unlock()
+	time.Sleep(time.Until(at))
+}
+
+// commit 按速度等待后提交 txn，提交后根据 backoff 调整速度
+func (t *throttle) commit(txn *transaction.KVTxn, keys int, bytes int64) error {
+	t.wait(keys, bytes)
+	var detail *util.CommitDetails
+	err := txn.Commit(context.WithValue(context.Background(), util.CommitDetailCtxKey, &detail))
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.observe(detail, err)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if t.pause > 0 && t.next.Before(now.Add(t.pause)) {
+		t.next = now.Add(t.pause)
+	}
+	t.keys += keys
+	t.bytes += bytes
+	t.winKeys += keys
+	t.winBytes += bytes
+	if elapsed := now.Sub(t.winStart).Seconds(); elapsed >= 1 || t.rate == "" && elapsed > 0 {
+		t.rate = fmt.Sprintf("%.0f keys/s, %s/s", float64(t.winKeys)/elapsed, utils.FormatSize(float64(t.winBytes)/elapsed))
+		t.winStart, t.winKeys, t.winBytes = now, 0, 0
+	}
+	return nil
+}
+
+// execute 用 begin 开启事务执行 fn，fn 返回这一批删除的 key 数和字节数，按速度提交；
+// 没有要删除的 key 时不提交。TiKV 忙导致提交失败时降速后重做这一批
+func (t *throttle) execute(begin func() (*transaction.KVTxn, error), fn func(txn *transaction.KVTxn) (int, int64, error)) error {
+	for attempt := 1; ; attempt++ {
+		txn, err := begin()
+		if err != nil {
+			return fmt.Errorf("transation begin err: %w", err)
+		}
+		keys, bytes, err := fn(txn)
+		if err != nil || keys == 0 {
+			_ = txn.Rollback()
+			return err
+		}
+		err = t.commit(txn, keys, bytes)
+		if err == nil {
+			return nil
+		}
+		if !isServerBusy(err) || attempt == busyRetries {
+			return fmt.Errorf("transation commit err: %w", err)
+		}
+		fmt.Printf("TiKV is busy, retrying the batch (%d/%d)\n", attempt, busyRetries-1)
+	}
+}
+
+// observe TiKV 忙时减半速度，否则每批恢复一些，直到设定的速度，未限速时恢复为不限速。调用时持有 t.mu
+func (t *throttle) observe(detail *util.CommitDetails, err error) {
+	busy := isServerBusy(err)
+	var backoff time.Duration
+	if detail != nil {
+		detail.Mu.Lock()
+		backoff = time.Duration(detail.Mu.CommitBackoffTime)
+		for _, types := range [][]string{detail.Mu.PrewriteBackoffTypes, detail.Mu.CommitBackoffTypes} {
+			for _, typ := range types {
+				busy = busy || strings.Contains(typ, "ServerBusy")
+			}
+		}
+		detail.Mu.Unlock()
+	}
+	if !busy && backoff < busyBackoff {
+		if t.factor < 1 {
+			t.factor = min(1, t.factor*1.2)
+			if t.factor == 1 && t.auto {
+				t.keysPerSec, t.auto = 0, false
+			}
+		}
+		return
+	}
+	if t.keysPerSec == 0 {
+		// 未限速时以目前的平均速度为基准
+		elapsed := time.Since(t.start).Seconds()
+		if t.keys == 0 || elapsed <= 0 {
+			t.keysPerSec = deleteBatchSize
+		} else {
+			t.keysPerSec = float64(t.keys) / elapsed
+		}
+		t.auto = true
+	}
+	t.factor = max(minThrottleRate, t.factor/2)
+	fmt.Printf("TiKV is busy (commit backoff %v), slowing down to %s\n", backoff.Round(time.Millisecond), t.limit())
+}
+
+// limit 当前生效的速度限制
+func (t *throttle) limit() string {
+	var parts []string
+	if t.keysPerSec > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f keys/s", t.keysPerSec*t.factor))
+	}
+	if t.bytesPerSec > 0 {
+		parts = append(parts, utils.FormatSize(t.bytesPerSec*t.factor)+"/s")
+	}
+	if len(parts) == 0 {
+		return "unlimited"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// progress 接在每批进度后面的吞吐，降速时附带当前的限制
+func (t *throttle) progress() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.rate == "" {
+		return ""
+	}
+	s := ", rate: " + t.rate
+	if t.factor < 1 {
+		s += " (slowed down to " + t.limit() + ")"
+	}
+	return s
+}
+
+// printSummary 结束时打印平均吞吐
+func (t *throttle) printSummary() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	elapsed := time.Since(t.start).Seconds()
+	if t.keys == 0 || elapsed <= 0 {
+		return
+	}
+	fmt.Printf("Average rate: %.0f keys/s, %s/s\n", float64(t.keys)/elapsed, utils.FormatSize(float64(t.bytes)/elapsed))
+}
+
+func isServerBusy(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, tikverr.ErrTiKVServerBusy) {
+		return true
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "server is busy") || strings.Contains(msg, "server busy")
+}
@@ -0,0 +1,30 @@
+package actions
+
+import (
+	"testing"
+	"tikv/utils"
+	"time"
+)
+
+// 确认提示等待的时间不计入速度，计时从第一批开始
+func TestThrottleStartsAtFirstBatch(t *testing.T) {
+	cl, _ := utils.ParseCommandLine("fd OS/T03/ -value=C003 -rate=100000")
+	fd, _ := lookupCommand("fd")
+	in, err := fd.parse(cl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	th, err := newThrottle(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !th.start.IsZero() {
+		t.Fatal("the clock started before the first batch")
+	}
+	time.Sleep(200 * time.Millisecond)
+	before := time.Now()
+	th.wait(10, 100)
+	if th.start.Before(before) || th.winStart != th.start {
+		t.Errorf("start = %v, want the time of the first batch (after %v)", th.start, before)
+	}
+}
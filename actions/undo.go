@@ -0,0 +1,281 @@
+package actions
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"math/rand"
+	"strings"
+	"tikv/base"
+	"tikv/utils"
+	"time"
+
+	"github.com/tikv/client-go/v2/txnkv/transaction"
+)
+
+const maxSkippedShown = 10
+
+// newOpID 操作 ID，以时间开头
+func newOpID() string {
+	return fmt.Sprintf("%s-%04x", time.Now().Format("20060102-150405"), rand.Intn(0x10000))
+}
+
+// logRecord 审计日志中的一条修改记录
+type logRecord struct {
+	action string
+	key    []byte
+	old    []byte // 修改前的值，nil 表示 key 原来不存在
+	value  []byte // set 写入的值
+	cmd    string
+	batch  uint64 // 所属的事务，0 表示写前日志之前的记录
+}
+
+func decodeLogRecord(r *utils.AuditRecord) (logRecord, error) {
+	rec := logRecord{action: r.Action, cmd: r.Cmd, batch: r.Batch}
+	var err error
+	if rec.key, err = base64.StdEncoding.DecodeString(r.Key); err != nil {
+		return rec, err
+	}
+	if r.Before != nil {
+		if rec.old, err = base64.StdEncoding.DecodeString(*r.Before); err != nil {
+			return rec, err
+		}
+	}
+	if r.After != nil {
+		rec.value, err = base64.StdEncoding.DecodeString(*r.After)
+	}
+	return rec, err
+}
+
+// opLogFiles undo 查找的日志文件，从最近写入的开始
+func opLogFiles() []string {
+	files, _ := utils.AuditFiles(base.LogDir)
+	for i, j := 0, len(files)-1; i < j; i, j = i+1, j-1 {
+		files[i], files[j] = files[j], files[i]
+	}
+	return files
+}
+
+// opRecords 日志中属于一个 op 的修改记录，以及有 commit 记录的批次
+type opRecords struct {
+	records   []logRecord
+	committed map[uint64]bool
+}
+
+// readOpRecords 按写入顺序读出日志文件中属于 op 的修改记录和提交记录
+func readOpRecords(path, op string) (*opRecords, error) {
+	o := &opRecords{committed: map[uint64]bool{}}
+	var bad error
+	err := utils.ReadAudit(path, func(line int, r *utils.AuditRecord) bool {
+		if r.Op != op {
+			return true
+		}
+		if r.Action == actionCommit {
+			o.committed[r.Batch] = true
+			return true
+		}
+		if r.Action != actionDelete && r.Action != actionSet {
+			return true
+		}
+		rec, err := decodeLogRecord(r)
+		if err != nil {
+			bad = fmt.Errorf("%s:%d: %v", path, line, err)
+			return false
+		}
+		o.records = append(o.records, rec)
+		return true
+	})
+	if bad != nil {
+		return nil, bad
+	}
+	return o, err
+}
+
+func (o *opRecords) empty() bool {
+	return len(o.records) == 0 && len(o.committed) == 0
+}
+
+// prepend 合并更早的日志文件中的记录
+func (o *opRecords) prepend(earlier *opRecords) {
+	o.records = append(earlier.records, o.records...)
+	for b := range earlier.committed {
+		o.committed[b] = true
+	}
+}
+
+// applied 实际提交了的修改。没有批次号的是写前日志之前的记录，写入时已经提交；
+// 批次没有 commit 记录的修改来自提交失败或重试前的事务，不能恢复
+func (o *opRecords) applied() (records []logRecord, uncommitted int) {
+	for _, r := range o.records {
+		if r.batch != 0 && !o.committed[r.batch] {
+			uncommitted++
+			continue
+		}
+		records = append(records, r)
+	}
+	return records, uncommitted
+}
+
+// handleUndo 在日志目录中查找 op 的记录并恢复。一条命令的记录是连续写入的，
+// 文件轮转时可能分在相邻的几个文件中，从最近的文件往前找齐
+func (c *TiKVClient) handleUndo(op string, force bool) {
+	ops := &opRecords{committed: map[uint64]bool{}}
+	var paths []string
+	files := opLogFiles()
+	oldest := false
+	for i, path := range files {
+		o, err := readOpRecords(path, op)
+		if err != nil {
+			fmt.Printf("read log err: %v\n", err)
+			c.status = ExitError
+			return
+		}
+		if o.empty() {
+			if !ops.empty() {
+				break
+			}
+			continue
+		}
+		ops.prepend(o)
+		paths = append([]string{path}, paths...)
+		oldest = i == len(files)-1
+	}
+	if len(ops.records) == 0 {
+		fmt.Printf("op %s not found in the logs under %s\n", op, base.LogDir)
+		c.status = ExitNotFound
+		return
+	}
+	if oldest && len(files) > 1 {
+		fmt.Printf("warning: op %s reaches back to the oldest log file, its earlier changes may have been removed by log retention\n", op)
+	}
+	c.restoreOp(strings.Join(paths, ", "), op, ops, force)
+}
+
+// handleRestore 从指定的日志文件恢复 op
+func (c *TiKVClient) handleRestore(path, op string, force bool) {
+	ops, err := readOpRecords(path, op)
+	if err != nil {
+		fmt.Printf("read log err: %v\n", err)
+		c.status = ExitError
+		return
+	}
+	if len(ops.records) == 0 {
+		fmt.Printf("op %s not found in %s\n", op, path)
+		c.status = ExitNotFound
+		return
+	}
+	c.restoreOp(path, op, ops, force)
+}
+
+// restoreOp 按与写入相反的顺序把 records 中的 key 恢复为修改前的值。
+// 之后又被重新创建或修改过的 key 默认跳过，-force 时照样恢复。
+// 每批在一个事务中先读当前值再写，恢复本身也写入日志，可以再次 undo
+func (c *TiKVClient) restoreOp(path, op string, ops *opRecords, force bool) {
+	records, uncommitted := ops.applied()
+	if uncommitted > 0 {
+		fmt.Printf("ignoring %d logged change(s) of op %s that were never committed\n", uncommitted, op)
+	}
+	if len(records) == 0 {
+		fmt.Printf("op %s has no committed changes in %s\n", op, path)
+		c.status = ExitNotFound
+		return
+	}
+	fmt.Printf("op %s: %d changes in %s, cmd: %s\n", op, len(records), path, records[0].cmd)
+	if !c.confirm("Are you sure to restore? (yes/no): ") {
+		return
+	}
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+
+	ctx := context.Background()
+	restored, already, skipped := 0, 0, 0
+	var skippedKeys [][]byte
+	for i := 0; i < len(records); i += deleteBatchSize {
+		batch := records[i:]
+		if len(batch) > deleteBatchSize {
+			batch = batch[:deleteBatchSize]
+		}
+		keys := make([][]byte, len(batch))
+		for k, r := range batch {
+			keys[k] = r.key
+		}
+
+		var bRestored, bAlready, bSkipped int
+		var bSkippedKeys [][]byte
+		log := c.newAuditBatch()
+		err := c.executeTxn(func(txn *transaction.KVTxn) error {
+			bRestored, bAlready, bSkipped, bSkippedKeys = 0, 0, 0, nil
+			log.reset()
+			current, err := txn.BatchGet(ctx, keys)
+			if err != nil {
+				return err
+			}
+			for _, r := range batch {
+				cur, exists := current[string(r.key)]
+				if !exists {
+					cur = nil
+				}
+				target := r.old
+				if exists == (target != nil) && bytes.Equal(cur, target) {
+					bAlready++
+					continue
+				}
+				// delete 之后 key 应当不存在，set 之后应当还是写入的值，否则说明之后又被改过
+				untouched := !exists
+				if r.action == actionSet {
+					untouched = exists && bytes.Equal(cur, r.value)
+				}
+				if !untouched && !force {
+					bSkipped++
+					bSkippedKeys = append(bSkippedKeys, r.key)
+					continue
+				}
+				if target == nil {
+					err = txn.Delete(r.key)
+					log.deleted(r.key, cur)
+				} else {
+					err = txn.Set(r.key, target)
+					log.set(r.key, cur, target)
+				}
+				if err != nil {
+					return fmt.Errorf("restore key=%s err: %v", c.fmtKey(r.key), err)
+				}
+				bRestored++
+				// 同一批中同一个 key 的更早记录要对照恢复后的值
+				if target == nil {
+					delete(current, string(r.key))
+				} else {
+					current[string(r.key)] = target
+				}
+			}
+			return log.write()
+		})
+		if err != nil {
+			fmt.Printf("operation failed: %v\n", err)
+			if restored > 0 {
+				fmt.Printf("%d keys were restored before stopping\n", restored)
+			}
+			c.status = ExitError
+			return
+		}
+		log.committed(c.commitTS)
+		restored += bRestored
+		already += bAlready
+		skipped += bSkipped
+		for _, k := range bSkippedKeys {
+			if len(skippedKeys) < maxSkippedShown {
+				skippedKeys = append(skippedKeys, k)
+			}
+		}
+	}
+
+	for _, k := range skippedKeys {
+		fmt.Printf("skipped, changed since: %s\n", c.fmtKey(k))
+	}
+	fmt.Printf("Total restored: %d, already restored: %d, skipped: %d\n", restored, already, skipped)
+	if skipped > 0 {
+		fmt.Println("skipped keys were re-created or changed after the op, use -force to restore them anyway")
+	}
+}
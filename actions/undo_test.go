@@ -0,0 +1,73 @@
+package actions
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"tikv/utils"
+)
+
+func writeTestLog(t *testing.T, path string, records []utils.AuditRecord) {
+	t.Helper()
+	var data []byte
+	for _, r := range records {
+		line, err := json.Marshal(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = append(append(data, line...), '\n')
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 没有 commit 记录的批次来自失败或重试前的事务，undo 不能用它的原值覆盖现在的数据
+func TestOpRecordsSkipUncommitted(t *testing.T) {
+	const op = "20250708-120000-abcd"
+	v1, v2 := b64([]byte("V1")), b64([]byte("V2"))
+	dir := t.TempDir()
+	older, newer := filepath.Join(dir, "audit-1.log"), filepath.Join(dir, "audit.log")
+	writeTestLog(t, older, []utils.AuditRecord{
+		{Op: op, Action: actionDelete, Key: b64([]byte("legacy")), Before: &v1},
+		{Op: op, Action: actionSet, Batch: 1, Key: b64([]byte("k1")), Before: &v1, After: &v2, Result: "pending"},
+		{Op: op, Action: actionCommit, Batch: 1, Result: "ok"},
+		// 提交时写冲突，重试后是另一个批次
+		{Op: op, Action: actionDelete, Batch: 2, Key: b64([]byte("k2")), Before: &v1, Result: "pending"},
+		{Op: op, Action: actionDelete, Batch: 3, Key: b64([]byte("k2")), Before: &v2, Result: "pending"},
+		{Op: "other", Action: actionCommit, Batch: 4, Result: "ok"},
+		{Op: op, Action: actionSet, Batch: 4, Key: b64([]byte("k3")), After: &v1, Result: "pending"},
+	})
+	// 文件轮转后 commit 记录在下一个文件中
+	writeTestLog(t, newer, []utils.AuditRecord{
+		{Op: op, Action: actionCommit, Batch: 3, Result: "ok"},
+		{Op: op, Action: actionEnd, Result: "ok"},
+	})
+
+	ops, err := readOpRecords(newer, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	earlier, err := readOpRecords(older, op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops.prepend(earlier)
+	records, uncommitted := ops.applied()
+	var got []string
+	for _, r := range records {
+		got = append(got, string(r.key)+"="+string(r.old))
+	}
+	want := []string{"legacy=V1", "k1=V1", "k2=V2"}
+	if !reflect.DeepEqual(got, want) || uncommitted != 2 {
+		t.Errorf("applied = %q, %d uncommitted, want %q, 2", got, uncommitted, want)
+	}
+
+	// 只读一个文件时，commit 在另一个文件中的批次也不恢复
+	records, uncommitted = earlier.applied()
+	if len(records) != 2 || uncommitted != 3 {
+		t.Errorf("applied in %s = %d records, %d uncommitted, want 2, 3", older, len(records), uncommitted)
+	}
+}
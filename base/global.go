@@ -1,11 +1,11 @@
 package base
 
 import (
-	"log"
-	"os"
+	"tikv/utils"
 )
 
 var (
-	GlobalLogger  *log.Logger
-	GlobalLogFile *os.File
+	AuditLog   *utils.AuditLog // 当前的审计日志，删除等修改数据的操作逐条写入
+	LogDir     string          // 日志目录，来自 -log-dir 或当前 profile
+	LogDirFlag string          // 启动参数 -log-dir
 )
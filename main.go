@@ -1,49 +1,228 @@
 package main
 
 import (
+	"bufio"
+	"flag"
 	"fmt"
 	"github.com/peterh/liner"
 	"github.com/pingcap/log"
 	"github.com/tikv/client-go/v2/txnkv"
 	"go.uber.org/zap"
+	"io"
+	"os"
 	"strings"
 	"tikv/actions"
 	"tikv/base"
 	"tikv/utils"
 )
 
-func start() {
-	base.GlobalLogger, base.GlobalLogFile, _ = utils.InitLog()
+var (
+	pdAddr      = flag.String("pd", "", "TiKV PD endpoints, comma separated")
+	profileName = flag.String("profile", "", "profile name in the config file")
+	configPath  = flag.String("config", utils.DefaultConfigPath(), "config file with cluster profiles")
+	caPath      = flag.String("ca", "", "CA certificate for TLS connections to PD and TiKV")
+	certPath    = flag.String("cert", "", "client certificate for mutual TLS")
+	keyPath     = flag.String("key", "", "client private key for mutual TLS")
+	execCmd     = flag.String("e", "", "execute one command and exit")
+	scriptFile  = flag.String("f", "", "execute commands from a script file and exit")
+	assumeYes   = flag.Bool("y", false, "answer yes to every delete confirmation")
+	output      = flag.String("o", "", "default output format of read commands: table|json|jsonl|csv|raw")
+	logDir      = flag.String("log-dir", "", "audit log directory, overrides the profile's log-dir (default ~/.tikvtool/logs)")
+)
 
-	line := liner.NewLiner()
-	defer line.Close()
+// resolveProfile 根据 -profile、配置文件中的 default 和 -pd 确定连接参数，
+// -pd 会覆盖 profile 中的地址；只给出 -pd 时不使用默认 profile；都没有时返回 nil
+func resolveProfile(cfg *utils.Config) (*utils.Profile, error) {
+	name := *profileName
+	if name == "" && *pdAddr == "" {
+		name = cfg.Default
+	}
+	var profile *utils.Profile
+	if name != "" {
+		p, err := cfg.Profile(name)
+		if err != nil {
+			return nil, err
+		}
+		copied := *p
+		profile = &copied
+	}
+	if *pdAddr != "" {
+		if profile == nil {
+			profile = &utils.Profile{}
+		}
+		profile.Endpoints = utils.ParseEndpoints(*pdAddr)
+	}
+	if profile != nil {
+		applySecurityFlags(profile)
+	}
+	return profile, nil
+}
 
-	line.SetCtrlCAborts(true)
+// applySecurityFlags 命令行中的证书参数覆盖 profile 中的配置
+func applySecurityFlags(p *utils.Profile) {
+	if *caPath != "" {
+		p.Security.CA = *caPath
+	}
+	if *certPath != "" {
+		p.Security.Cert = *certPath
+	}
+	if *keyPath != "" {
+		p.Security.Key = *keyPath
+	}
+}
+
+func start() int {
+	interactive := *execCmd == "" && *scriptFile == ""
+	if *output != "" {
+		if err := actions.ValidFormat(*output); err != nil {
+			fmt.Println(err)
+			return actions.ExitError
+		}
+	}
 
-	// 获取TiKV地址
-	fmt.Print(" Enter Tikv address cluster: ")
-	endpoints, err := line.Prompt("")
+	cfg, err := utils.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Println(err)
+		return actions.ExitError
+	}
+	profile, err := resolveProfile(cfg)
 	if err != nil {
-		panic(err)
+		fmt.Println(err)
+		return actions.ExitError
+	}
+
+	var line *liner.State
+	if interactive {
+		line = liner.NewLiner()
+		defer line.Close()
+		line.SetCtrlCAborts(true)
 	}
-	endpoints = strings.TrimSpace(endpoints)
-	addrs := strings.Split(endpoints, ",")
 
 	log.SetLevel(zap.ErrorLevel)
-	client, err := txnkv.NewClient(addrs)
-	defer client.Close()
-	if err != nil {
-		fmt.Printf("connect to tikv err:", err)
-		return
+	var client *txnkv.Client
+	if profile != nil {
+		client, err = actions.Connect(profile)
+		if err != nil {
+			fmt.Println("connect to tikv err:", err)
+			return actions.ExitError
+		}
+	} else if !interactive {
+		fmt.Println("-pd or -profile is required with -e or -f")
+		return actions.ExitError
+	} else {
+		// 获取TiKV地址，输入错误时重新输入；也可以直接输入 profile 名称
+		for client == nil {
+			fmt.Print(" Enter Tikv address cluster: ")
+			input, err := line.Prompt("")
+			if err != nil {
+				return actions.ExitCancelled
+			}
+			input = strings.TrimSpace(input)
+			if p, ok := cfg.Profiles[input]; ok {
+				copied := *p
+				profile = &copied
+			} else {
+				profile = &utils.Profile{Endpoints: utils.ParseEndpoints(input)}
+			}
+			applySecurityFlags(profile)
+			client, err = actions.Connect(profile)
+			if err != nil {
+				fmt.Println("connect to tikv err:", err)
+			}
+		}
+	}
+
+	base.LogDirFlag = *logDir
+	actions.ApplyProfile(profile)
+	cli := &actions.TiKVClient{Client: client, Config: cfg, Profile: profile, AssumeYes: *assumeYes, Output: *output}
+	// connect/use 会替换连接和审计日志，退出时关闭当前的
+	defer func() {
+		_ = cli.Client.Close()
+		base.AuditLog.Close()
+	}()
+	switch {
+	case *execCmd != "":
+		return runCommand(cli, *execCmd)
+	case *scriptFile != "":
+		return runScript(cli, *scriptFile)
 	}
-	fmt.Println("successful connected")
 
+	fmt.Println("successful connected")
 	// 初始化命令行界面
-	cli := &actions.TiKVClient{client}
 	cli.StartCmd(line)
+	return actions.ExitOK
+}
 
-	defer base.GlobalLogFile.Close()
+// runCommand 非交互执行单条命令
+func runCommand(cli *actions.TiKVClient, input string) int {
+	cl, err := utils.ParseCommandLine(input)
+	if err != nil {
+		fmt.Println("parse command err:", err)
+		return actions.ExitError
+	}
+	if len(cl.Tokens) == 0 {
+		return actions.ExitOK
+	}
+	code, _ := cli.Exec(cl)
+	return code
 }
+
+// runScript 逐条执行脚本，空行和 # 开头的行忽略，命令可以跨行（引号、续行、heredoc）；
+// 遇到错误或取消立即停止，key 不存在不中断执行，但最终退出码保留 not found
+func runScript(cli *actions.TiKVClient, path string) int {
+	file, err := os.Open(path)
+	if err != nil {
+		fmt.Println("open script err:", err)
+		return actions.ExitError
+	}
+	defer file.Close()
+
+	result := actions.ExitOK
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	lineNo := 0
+	next := func() (string, error) {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.ErrUnexpectedEOF
+		}
+		lineNo++
+		return scanner.Text(), nil
+	}
+	for scanner.Scan() {
+		lineNo++
+		start := lineNo
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		cl, err := utils.ReadCommand(text, next)
+		if err != nil {
+			fmt.Printf("%s:%d: parse command err: %v\n", path, start, err)
+			return actions.ExitError
+		}
+		code, quit := cli.Exec(cl)
+		if quit {
+			break
+		}
+		if code == actions.ExitError || code == actions.ExitCancelled {
+			fmt.Printf("%s:%d: command failed: %s\n", path, start, trimmed)
+			return code
+		}
+		if code != actions.ExitOK {
+			result = code
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Println("read script err:", err)
+		return actions.ExitError
+	}
+	return result
+}
+
 func main() {
 	//utils.DataAdd()
 	// 设置全局 panic 处理
@@ -53,5 +232,6 @@ func main() {
 	//		// 可以在这里进行错误上报、资源清理等操作
 	//	}
 	//}()
-	start()
+	flag.Parse()
+	os.Exit(start())
 }
@@ -0,0 +1,344 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// AuditRecord 审计日志中的一行。key 和 value 用 base64 保存，任何字节都能原样还原。
+// Hash 是 Prev 与本条其余内容的 sha256，Prev 是上一条的 Hash，改动或删除任何一行都会使之后的校验失败
+type AuditRecord struct {
+	Time      string   `json:"time"`
+	Op        string   `json:"op"`
+	User      string   `json:"user"`
+	Host      string   `json:"host"`
+	Profile   string   `json:"profile,omitempty"`
+	Endpoints []string `json:"endpoints,omitempty"`
+	Cmd       string   `json:"cmd"`
+	Action    string   `json:"action"`           // delete、set、commit、purge，命令结束时为 end
+	Batch     uint64   `json:"batch,omitempty"`  // delete、set 所属的事务，提交成功后有一条同 batch 的 commit
+	Key       string   `json:"key,omitempty"`    // base64
+	End       string   `json:"end,omitempty"`    // purge 范围的上界，base64
+	Before    *string  `json:"before,omitempty"` // 修改前的值，base64；set 时为空表示 key 原来不存在
+	After     *string  `json:"after,omitempty"`  // set 写入的值，base64
+	CommitTS  uint64   `json:"commitTs,omitempty"`
+	Result    string   `json:"result"`
+	Prev      string   `json:"prev"`
+	Hash      string   `json:"hash"`
+}
+
+// hash 计算 Hash 字段为空时整条记录的 sha256
+func (r AuditRecord) hash() (string, error) {
+	r.Hash = ""
+	data, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// 审计日志文件名：正在写入的是 tikvcli-audit.log，轮转出的文件带轮转时间，
+// 如 tikvcli-audit-2026-01-02T15-04-05.000.log.gz；tikvcli-YYYYMMDD.log 是按天分文件时的旧日志
+const (
+	auditBase   = "tikvcli-audit"
+	auditActive = auditBase + ".log"
+)
+
+// AuditLog 追加写入的审计日志，记录之间按 hash 串成链，文件轮转后链在新文件中继续
+type AuditLog struct {
+	mu     sync.Mutex
+	w      *lumberjack.Logger
+	prev   string
+	day    string // 当前文件最后写入的日期，换天时轮转
+	closed bool
+}
+
+// AuditFiles dir 下的全部审计日志文件，按写入的先后排序：旧的按天分的文件，轮转出的文件，正在写入的文件
+func AuditFiles(dir string) ([]string, error) {
+	legacy, err := filepath.Glob(filepath.Join(dir, "tikvcli-[0-9]*.log"))
+	if err != nil {
+		return nil, err
+	}
+	rotated, err := filepath.Glob(filepath.Join(dir, auditBase+"-*.log*"))
+	if err != nil {
+		return nil, err
+	}
+	files := legacy
+	sort.Strings(files)
+	sort.Strings(rotated)
+	for i, f := range rotated {
+		// 正在压缩的文件 .log 和 .log.gz 同时存在，.log.gz 还不完整
+		if strings.HasSuffix(f, ".log") || strings.HasSuffix(f, ".log.gz") && (i == 0 || rotated[i-1]+".gz" != f) {
+			files = append(files, f)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, auditActive)); err == nil {
+		files = append(files, filepath.Join(dir, auditActive))
+	}
+	return files, nil
+}
+
+// AuditChained a 之后是否紧接着写入 b，即 b 的第一条记录链接 a 的最后一条。
+// 轮转出的文件和正在写入的文件是一条链，旧的按天分的文件各自独立
+func AuditChained(a, b string) bool {
+	return strings.HasPrefix(filepath.Base(a), auditBase) && strings.HasPrefix(filepath.Base(b), auditBase)
+}
+
+// InitLog 打开 dir 下的审计日志，从最后一条记录的 hash 继续串链。
+// 文件超过 MaxSize 或换天时轮转，轮转出的文件按 cfg 压缩和清理
+func InitLog(dir string, cfg LogConfig) (*AuditLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("create log dir err: %v", err)
+	}
+	l := &AuditLog{
+		w: &lumberjack.Logger{
+			Filename:   filepath.Join(dir, auditActive),
+			MaxSize:    cfg.MaxSize,
+			MaxAge:     cfg.MaxAge,
+			MaxBackups: cfg.MaxBackups,
+			LocalTime:  true,
+			Compress:   cfg.Compress == nil || *cfg.Compress,
+		},
+		day: time.Now().Format("20060102"),
+	}
+	files, err := AuditFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	// 正在写入的文件为空时（刚轮转过）从最近轮转出的文件继续
+	for i := len(files) - 1; i >= 0 && l.prev == "" && AuditChained(files[i], l.w.Filename); i-- {
+		if l.prev, err = lastAuditHash(files[i]); err != nil {
+			return nil, fmt.Errorf("read log file err: %v", err)
+		}
+	}
+	if fi, err := os.Stat(l.w.Filename); err == nil && fi.Size() > 0 {
+		l.day = fi.ModTime().Format("20060102")
+	}
+	return l, nil
+}
+
+// lastAuditHash 文件中最后一条记录的 hash，文件不存在或为空时为空串。
+// 从文件末尾向前读到最后一行为止，不读整个文件；压缩的文件只能从头读
+func lastAuditHash(name string) (string, error) {
+	if strings.HasSuffix(name, ".gz") {
+		last := ""
+		err := ReadAudit(name, func(_ int, r *AuditRecord) bool {
+			last = r.Hash
+			return true
+		})
+		if os.IsNotExist(err) {
+			err = nil
+		}
+		return last, err
+	}
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	size := fi.Size()
+	var line []byte
+	for chunk := int64(64 * 1024); ; chunk *= 4 {
+		if chunk > size {
+			chunk = size
+		}
+		buf := make([]byte, chunk)
+		if _, err := f.ReadAt(buf, size-chunk); err != nil {
+			return "", err
+		}
+		buf = bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(buf, '\n'); i >= 0 || chunk == size {
+			line = buf[i+1:]
+			break
+		}
+	}
+	if len(line) == 0 {
+		return "", nil
+	}
+	var r AuditRecord
+	if err := json.Unmarshal(line, &r); err != nil {
+		return "", fmt.Errorf("%s: last line is not an audit record: %v", name, err)
+	}
+	return r.Hash, nil
+}
+
+// Write 补全 Time、Prev、Hash 后追加一行，l 为 nil 或已关闭时返回错误
+func (l *AuditLog) Write(r *AuditRecord) error {
+	if l == nil {
+		return fmt.Errorf("audit log is not open")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return fmt.Errorf("audit log is closed")
+	}
+	now := time.Now()
+	if r.Time == "" {
+		r.Time = now.Format(time.RFC3339Nano)
+	}
+	if day := now.Format("20060102"); day != l.day {
+		if err := l.w.Rotate(); err != nil {
+			return fmt.Errorf("rotate log file err: %v", err)
+		}
+		l.day = day
+	}
+	r.Prev = l.prev
+	h, err := r.hash()
+	if err != nil {
+		return err
+	}
+	r.Hash = h
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	l.prev = h
+	return nil
+}
+
+// Writable 检查日志文件能否追加写入，修改数据的命令开始前调用
+func (l *AuditLog) Writable() error {
+	if l == nil {
+		return fmt.Errorf("audit log is not open")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return fmt.Errorf("audit log is closed")
+	}
+	f, err := os.OpenFile(l.w.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Sync 把已写入的记录落盘。lumberjack 不提供 Sync，fsync 作用于文件本身，另开一个句柄即可
+func (l *AuditLog) Sync() error {
+	if l == nil {
+		return fmt.Errorf("audit log is not open")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return fmt.Errorf("audit log is closed")
+	}
+	f, err := os.OpenFile(l.w.Filename, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
+
+func (l *AuditLog) Close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.closed {
+		_ = l.w.Close()
+		l.closed = true
+	}
+}
+
+// ReadAudit 逐行读取审计日志，.gz 结尾的文件先解压，fn 返回 false 时停止
+func ReadAudit(path string, fn func(line int, r *AuditRecord) bool) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) && strings.HasPrefix(filepath.Base(path), auditBase+"-") {
+		// 轮转出的文件在列出之后压缩完成
+		path += ".gz"
+		f, err = os.Open(path)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var rd io.Reader = f
+	if strings.HasSuffix(path, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		defer gz.Close()
+		rd = gz
+	}
+	sc := bufio.NewScanner(rd)
+	sc.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		var r AuditRecord
+		if err := json.Unmarshal(sc.Bytes(), &r); err != nil {
+			return fmt.Errorf("%s:%d: not an audit record: %v", path, n, err)
+		}
+		if !fn(n, &r) {
+			break
+		}
+	}
+	return sc.Err()
+}
+
+// VerifyAudit 按顺序校验文件中每条记录的 hash 以及与上一条的链接。
+// prev 为上一个文件最后的 hash，第一个文件传空串时不检查第一条的 Prev；返回最后一条的 hash 和记录数
+func VerifyAudit(path, prev string) (last string, n int, err error) {
+	last = prev
+	var bad error
+	err = ReadAudit(path, func(line int, r *AuditRecord) bool {
+		h, herr := r.hash()
+		switch {
+		case herr != nil:
+			bad = fmt.Errorf("%s:%d: %v", path, line, herr)
+		case h != r.Hash:
+			bad = fmt.Errorf("%s:%d: record was modified (hash mismatch)", path, line)
+		case last != "" && r.Prev != last:
+			bad = fmt.Errorf("%s:%d: chain broken, a record before this line was removed or modified", path, line)
+		}
+		if bad != nil {
+			return false
+		}
+		last = r.Hash
+		n++
+		return true
+	})
+	if bad != nil {
+		err = bad
+	}
+	return last, n, err
+}
+
+// AuditIdentity 写入审计记录的操作系统用户和主机名
+func AuditIdentity() (userName, host string) {
+	if u, err := user.Current(); err == nil {
+		userName = u.Username
+	} else {
+		userName = os.Getenv("USER")
+	}
+	host, _ = os.Hostname()
+	return userName, host
+}
@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuditLogWritableSync(t *testing.T) {
+	var nilLog *AuditLog
+	if nilLog.Writable() == nil || nilLog.Sync() == nil {
+		t.Error("a log that was never opened should not be writable")
+	}
+
+	dir := t.TempDir()
+	l, err := InitLog(dir, LogConfig{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.Writable(); err != nil {
+		t.Fatalf("Writable: %v", err)
+	}
+	for _, action := range []string{"delete", "commit"} {
+		if err := l.Write(&AuditRecord{Op: "op1", Action: action, Batch: 1, Result: "ok"}); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+	if err := l.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if _, n, err := VerifyAudit(filepath.Join(dir, auditActive), ""); err != nil || n != 2 {
+		t.Fatalf("VerifyAudit = %d records, %v", n, err)
+	}
+
+	l.Close()
+	if l.Writable() == nil || l.Sync() == nil || l.Write(&AuditRecord{Action: "end"}) == nil {
+		t.Error("a closed log should not be writable")
+	}
+
+	// 日志目录被删除或不可写时，修改数据的命令开始前就能发现
+	l, err = InitLog(dir, LogConfig{MaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if l.Writable() == nil {
+		t.Error("Writable should fail when the log directory is gone")
+	}
+}
+
+// writeRotatedAudit 写 3 条记录后轮转，再写 3 条，返回按写入顺序排列的两个文件
+func writeRotatedAudit(t *testing.T) []string {
+	t.Helper()
+	dir := t.TempDir()
+	compress := false
+	l, err := InitLog(dir, LogConfig{MaxSize: 1, Compress: &compress})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 6; i++ {
+		if err := l.Write(&AuditRecord{Op: "op1", Action: "delete", Key: fmt.Sprintf("k%d", i), Result: "pending"}); err != nil {
+			t.Fatal(err)
+		}
+		if i == 3 {
+			if err := l.w.Rotate(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	l.Close()
+	files, err := AuditFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("AuditFiles = %v, want a rotated and an active file", files)
+	}
+	return files
+}
+
+// verifyChain 与 audit verify 一样按顺序校验各文件，前一个文件的最后一条链接到后一个文件
+func verifyChain(files []string) (int, error) {
+	total, prev := 0, ""
+	for i, f := range files {
+		if i == 0 || !AuditChained(files[i-1], f) {
+			prev = ""
+		}
+		last, n, err := VerifyAudit(f, prev)
+		if err != nil {
+			return total, err
+		}
+		prev = last
+		total += n
+	}
+	return total, nil
+}
+
+func editLines(t *testing.T, path string, edit func(lines []string) []string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := edit(strings.Split(strings.TrimSuffix(string(data), "\n"), "\n"))
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// rewrite 改动一条记录的 key；rehash 时重新计算它的 hash，模拟有意的篡改
+func rewrite(t *testing.T, line string, rehash bool) string {
+	var r AuditRecord
+	if err := json.Unmarshal([]byte(line), &r); err != nil {
+		t.Fatal(err)
+	}
+	r.Key = "tampered"
+	if rehash {
+		r.Hash, _ = r.hash()
+	}
+	data, _ := json.Marshal(r)
+	return string(data)
+}
+
+func TestVerifyAuditTampered(t *testing.T) {
+	if n, err := verifyChain(writeRotatedAudit(t)); err != nil || n != 6 {
+		t.Fatalf("untouched log: %d records, %v", n, err)
+	}
+
+	tests := []struct {
+		name string
+		file int // 0 为轮转出的文件，1 为正在写入的文件
+		edit func(t *testing.T, lines []string) []string
+		want string
+	}{
+		{"modified", 0, func(t *testing.T, l []string) []string {
+			l[1] = rewrite(t, l[1], false)
+			return l
+		}, "hash mismatch"},
+		{"modified and rehashed", 1, func(t *testing.T, l []string) []string {
+			l[0] = rewrite(t, l[0], true)
+			return l
+		}, "chain broken"},
+		{"rehashed last record before rotation", 0, func(t *testing.T, l []string) []string {
+			l[2] = rewrite(t, l[2], true)
+			return l
+		}, "chain broken"},
+		{"removed", 1, func(t *testing.T, l []string) []string {
+			return append(l[:1], l[2:]...)
+		}, "chain broken"},
+		{"removed last record before rotation", 0, func(t *testing.T, l []string) []string {
+			return l[:2]
+		}, "chain broken"},
+		{"removed first record after rotation", 1, func(t *testing.T, l []string) []string {
+			return l[1:]
+		}, "chain broken"},
+		{"reordered", 0, func(t *testing.T, l []string) []string {
+			l[0], l[1] = l[1], l[0]
+			return l
+		}, "chain broken"},
+	}
+	for _, tt := range tests {
+		files := writeRotatedAudit(t)
+		editLines(t, files[tt.file], func(lines []string) []string { return tt.edit(t, lines) })
+		_, err := verifyChain(files)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: verify = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 字节的显示方式
+const (
+	FmtAuto   = "auto"   // 可打印的 UTF-8 原样显示，否则转义
+	FmtHex    = "hex"    // 十六进制
+	FmtEscape = "escape" // 不可打印字符和非法 UTF-8 字节写成 \xNN
+	FmtUTF8   = "utf8"   // 原样输出
+)
+
+var ByteFormats = []string{FmtAuto, FmtHex, FmtEscape, FmtUTF8}
+
+var hexLiteral = regexp.MustCompile(`^[xX]'[^']*'$`)
+
+// ParseLiteral 将参数解析为字节：
+//
+//	x'4f53'     十六进制
+//	b64:T1M=    base64
+//
+// 其余写法取 Tokenize 处理引号和转义后的内容，如 "OS\x00"
+func ParseLiteral(tok Token) ([]byte, error) {
+	raw := tok.Raw
+	switch {
+	case hexLiteral.MatchString(raw):
+		b, err := hex.DecodeString(raw[2 : len(raw)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex literal %s: %v", raw, err)
+		}
+		return b, nil
+	case !tok.Quoted && strings.HasPrefix(raw, "b64:"):
+		b, err := base64.StdEncoding.DecodeString(raw[4:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 literal %s: %v", raw, err)
+		}
+		return b, nil
+	}
+	return []byte(tok.Text), nil
+}
+
+// FormatBytes 按显示方式把字节转成文本
+func FormatBytes(b []byte, mode string) string {
+	switch mode {
+	case FmtHex:
+		return hex.EncodeToString(b)
+	case FmtEscape:
+		return escapeBytes(b)
+	case FmtUTF8:
+		return string(b)
+	}
+	if isPrintable(b) {
+		return string(b)
+	}
+	return escapeBytes(b)
+}
+
+func isPrintable(b []byte) bool {
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		if r == utf8.RuneError && size == 1 {
+			return false
+		}
+		if !unicode.IsPrint(r) && r != '\t' {
+			return false
+		}
+		b = b[size:]
+	}
+	return true
+}
+
+// escapeBytes 只用 \xNN、\\ 和 \" 转义，结果加上双引号即可作为 "..." 参数重新输入
+func escapeBytes(b []byte) string {
+	var sb strings.Builder
+	for len(b) > 0 {
+		r, size := utf8.DecodeRune(b)
+		switch {
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '"':
+			sb.WriteString(`\"`)
+		case r != utf8.RuneError && unicode.IsPrint(r):
+			sb.WriteRune(r)
+		default:
+			for _, c := range b[:size] {
+				fmt.Fprintf(&sb, `\x%02x`, c)
+			}
+		}
+		b = b[size:]
+	}
+	return sb.String()
+}
+
+// ReadKeyList 读取 key 列表文件，每行一个 key，写法与命令参数相同；
+// 跳过空行和 # 开头的注释行
+func ReadKeyList(path string) ([][]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys [][]byte
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// 整行是一个加引号或字面量写法的参数时按字面量解析，否则整行就是 key
+		tok := Token{Text: line, Raw: line}
+		if toks, err := Tokenize(line); err == nil && len(toks) == 1 && toks[0].Start == 0 && toks[0].End == len(line) {
+			tok = toks[0]
+		}
+		key, err := ParseLiteral(tok)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		keys = append(keys, key)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		arg  string
+		want []byte
+	}{
+		{"OS/T03/config", []byte("OS/T03/config")},
+		{`x'4f5300'`, []byte("OS\x00")},
+		{`X'4F53'`, []byte("OS")},
+		{"b64:T1M=", []byte("OS")},
+		{`"b64:T1M="`, []byte("b64:T1M=")},
+		{`"OS\x00\xff"`, []byte("OS\x00\xff")},
+		{`"C:\temp"`, []byte(`C:\temp`)},
+		{`"\d+"`, []byte(`\d+`)},
+		{`"a\\b \"c\""`, []byte(`a\b "c"`)},
+		{`'\x00'`, []byte(`\x00`)},
+		{`"中文"`, []byte("中文")},
+	}
+	for _, tt := range tests {
+		toks, err := Tokenize(tt.arg)
+		if err != nil || len(toks) != 1 {
+			t.Fatalf("Tokenize(%q) = %v, %v", tt.arg, toks, err)
+		}
+		got, err := ParseLiteral(toks[0])
+		if err != nil {
+			t.Errorf("ParseLiteral(%q): %v", tt.arg, err)
+			continue
+		}
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("ParseLiteral(%q) = %q, want %q", tt.arg, got, tt.want)
+		}
+	}
+}
+
+func TestParseLiteralInvalid(t *testing.T) {
+	for _, arg := range []string{`x'4f5'`, `x'zz'`, "b64:***"} {
+		toks, _ := Tokenize(arg)
+		if _, err := ParseLiteral(toks[0]); err == nil {
+			t.Errorf("ParseLiteral(%q) should fail", arg)
+		}
+	}
+}
+
+// 转义显示的结果加上双引号后能原样解析回来
+func TestEscapeRoundTrip(t *testing.T) {
+	for _, b := range [][]byte{
+		[]byte("OS/T03/config"),
+		[]byte("OS\x00\x01\xff"),
+		[]byte("a\tb\nc"),
+		[]byte(`C:\temp "x"`),
+		[]byte("中文\xe4\xb8"),
+		{},
+	} {
+		s := FormatBytes(b, FmtEscape)
+		toks, err := Tokenize(`"` + s + `"`)
+		if err != nil || len(toks) != 1 {
+			t.Fatalf("Tokenize(%q) = %v, %v", s, toks, err)
+		}
+		got, err := ParseLiteral(toks[0])
+		if err != nil || !bytes.Equal(got, b) {
+			t.Errorf("round trip %q -> %q -> %q, %v", b, s, got, err)
+		}
+	}
+}
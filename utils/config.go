@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Security TLS 证书配置，CA 为空时使用明文连接
+type Security struct {
+	CA       string   `yaml:"ca"`
+	Cert     string   `yaml:"cert"`
+	Key      string   `yaml:"key"`
+	VerifyCN []string `yaml:"verify-cn"`
+}
+
+// Enabled 是否启用 TLS
+func (s Security) Enabled() bool {
+	return s.CA != ""
+}
+
+// Validate 检查证书配置是否完整
+func (s Security) Validate() error {
+	if (s.Cert == "") != (s.Key == "") {
+		return errors.New("cert and key must be given together")
+	}
+	if s.Cert != "" && s.CA == "" {
+		return errors.New("ca is required when cert and key are given")
+	}
+	return nil
+}
+
+// Profile 一个集群的连接配置
+type Profile struct {
+	Name      string            `yaml:"-"`
+	Endpoints []string          `yaml:"endpoints"`
+	Security  Security          `yaml:"security"`
+	Timezone  string            `yaml:"timezone"`
+	LogDir    string            `yaml:"log-dir"` // 审计日志目录，默认 ~/.tikvtool/logs
+	Log       LogConfig         `yaml:"log"`
+	Defaults  map[string]string `yaml:"defaults"`           // 命令选项的默认值，如 limit: 100
+	Admin     bool              `yaml:"admin"`              // 允许 purge 等管理命令
+	Protected []string          `yaml:"protected-prefixes"` // purge 默认拒绝覆盖的前缀
+}
+
+// LogConfig 审计日志的轮转和保留，轮转出的文件按 max-age 和 max-backups 清理，都为 0 时全部保留
+type LogConfig struct {
+	MaxSize    int   `yaml:"max-size"`    // 单个文件的大小上限，MB，默认 100；另外每天轮转一次
+	MaxAge     int   `yaml:"max-age"`     // 轮转出的文件保留的天数
+	MaxBackups int   `yaml:"max-backups"` // 轮转出的文件最多保留的个数
+	Compress   *bool `yaml:"compress"`    // 轮转出的文件是否 gzip 压缩，默认压缩
+}
+
+// Config ~/.tikvtool.yaml 的内容
+type Config struct {
+	Default  string              `yaml:"default"`
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+// DefaultLogDir 默认审计日志目录 ~/.tikvtool/logs
+func DefaultLogDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "tikvtool-logs"
+	}
+	return filepath.Join(home, ".tikvtool", "logs")
+}
+
+// DefaultConfigPath 默认配置文件路径 ~/.tikvtool.yaml
+func DefaultConfigPath() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".tikvtool.yaml"
+	}
+	return filepath.Join(home, ".tikvtool.yaml")
+}
+
+// LoadConfig 读取配置文件；文件不存在时返回空配置
+func LoadConfig(path string) (*Config, error) {
+	cfg := &Config{Profiles: map[string]*Profile{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cfg, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config err: %v", err)
+	}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse config %s err: %v", path, err)
+	}
+	if cfg.Profiles == nil {
+		cfg.Profiles = map[string]*Profile{}
+	}
+	for name, p := range cfg.Profiles {
+		if p == nil {
+			return nil, fmt.Errorf("profile %s is empty", name)
+		}
+		p.Name = name
+		if err := p.Security.Validate(); err != nil {
+			return nil, fmt.Errorf("profile %s: %v", name, err)
+		}
+		if p.Timezone != "" {
+			if _, err := time.LoadLocation(p.Timezone); err != nil {
+				return nil, fmt.Errorf("profile %s: unknown timezone %s", name, p.Timezone)
+			}
+		}
+	}
+	if cfg.Default != "" && cfg.Profiles[cfg.Default] == nil {
+		return nil, fmt.Errorf("default profile %s not found in %s", cfg.Default, path)
+	}
+	return cfg, nil
+}
+
+// Profile 按名称查找 profile
+func (c *Config) Profile(name string) (*Profile, error) {
+	p, ok := c.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %s not found, available: %s", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseEndpoints 解析逗号分隔的 PD 地址
+func ParseEndpoints(s string) []string {
+	var addrs []string
+	for _, addr := range strings.Split(s, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
@@ -29,16 +29,32 @@ import (
 //	return uint64(startTime.UnixMilli()) << 18
 //}
 
+// DefaultTimezone profile 未配置时区时使用的时区
+const DefaultTimezone = "Asia/Shanghai"
+
 var cst *time.Location
 
 func init() {
 	// 初始化时区（Asia/Shanghai）
 	var err error
-	cst, err = time.LoadLocation("Asia/Shanghai")
+	cst, err = time.LoadLocation(DefaultTimezone)
 	if err != nil {
 		panic(err)
 	}
 }
+
+// SetTimezone 切换时间转换使用的时区，name 为空时恢复默认时区
+func SetTimezone(name string) error {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return fmt.Errorf("load timezone %s err: %v", name, err)
+	}
+	cst = loc
+	return nil
+}
 func TikvTimeFormat(startTS uint64) string {
 	// 物理时间（毫秒）→ CST 时间字符串
 	return time.UnixMilli(int64(startTS >> 18)).In(cst).Format("2006-01-02 15:04:05")
@@ -54,6 +70,20 @@ func TimeToTS(physical string) uint64 {
 	return uint64(startTime.UnixMilli()) << 18
 }
 
+// ParseTime 按 profile 时区解析 "2006-01-02 15:04:05"
+func ParseTime(s string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04:05", s, cst)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, use \"2006-01-02 15:04:05\"", s)
+	}
+	return t, nil
+}
+
+// FormatMillis 毫秒时间戳按 profile 时区显示，与 lockTime 的单位一致
+func FormatMillis(ms int64) string {
+	return time.UnixMilli(ms).In(cst).Format("2006-01-02 15:04:05")
+}
+
 func DataAdd() {
 	client, _ := txnkv.NewClient(strings.Split("10.0.11.33:2379,10.0.11.34:2379,10.0.11.35:2379", ","))
 	txn, _ := client.Begin()
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const tlsProbeTimeout = 5 * time.Second
+
+// hostPort 去掉 PD 地址中的 http:// 或 https:// 前缀
+func hostPort(endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	return strings.TrimSuffix(endpoint, "/")
+}
+
+// CheckTLS 用 cfg 与 endpoint 做一次 TLS 握手，失败时返回说明原因的错误
+func CheckTLS(endpoint string, cfg *tls.Config) error {
+	addr := hostPort(endpoint)
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint %s: %v", endpoint, err)
+	}
+	cfg = cfg.Clone()
+	cfg.ServerName = host
+
+	dialer := &net.Dialer{Timeout: tlsProbeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, cfg)
+	if err != nil {
+		return describeTLSError(addr, err)
+	}
+	defer conn.Close()
+
+	// TLS 1.3 下服务端在握手完成后才校验客户端证书，拒绝时会随后发来 alert 或直接断开
+	_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+	_, err = conn.Read(make([]byte, 1))
+	var netErr net.Error
+	if err == nil || errors.As(err, &netErr) && netErr.Timeout() {
+		return nil
+	}
+	if strings.Contains(err.Error(), "remote error") {
+		return describeTLSError(addr, err)
+	}
+	if cfg.GetClientCertificate != nil {
+		return fmt.Errorf("TLS handshake with %s failed: the server closed the connection right after the handshake, it probably rejected the client certificate: %v", addr, err)
+	}
+	return fmt.Errorf("TLS handshake with %s failed: the server closed the connection right after the handshake, it may require a client certificate: %v", addr, err)
+}
+
+// SpeaksTLS 探测 endpoint 是否为 TLS 端口，用于明文连接失败时给出提示
+func SpeaksTLS(endpoint string) bool {
+	dialer := &net.Dialer{Timeout: tlsProbeTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", hostPort(endpoint), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return false
+	}
+	_ = conn.Close()
+	return true
+}
+
+func describeTLSError(addr string, err error) error {
+	var (
+		unknownAuthority x509.UnknownAuthorityError
+		hostnameErr      x509.HostnameError
+		invalidCert      x509.CertificateInvalidError
+		recordHeaderErr  tls.RecordHeaderError
+		netErr           net.Error
+	)
+	switch {
+	case errors.As(err, &unknownAuthority):
+		return fmt.Errorf("TLS handshake with %s failed: server certificate is not signed by the configured CA", addr)
+	case errors.As(err, &hostnameErr):
+		return fmt.Errorf("TLS handshake with %s failed: server certificate is not valid for this address (%v)", addr, hostnameErr)
+	case errors.As(err, &invalidCert):
+		return fmt.Errorf("TLS handshake with %s failed: server certificate is invalid: %v", addr, invalidCert)
+	case errors.As(err, &recordHeaderErr):
+		return fmt.Errorf("TLS handshake with %s failed: the server did not answer with TLS, is it a plain-text endpoint?", addr)
+	case strings.Contains(err.Error(), "bad certificate"),
+		strings.Contains(err.Error(), "certificate required"),
+		strings.Contains(err.Error(), "unknown certificate authority"):
+		return fmt.Errorf("TLS handshake with %s failed: the server rejected the client certificate, check cert and key: %v", addr, err)
+	case errors.As(err, &netErr):
+		return fmt.Errorf("cannot reach %s: %v", addr, err)
+	}
+	return fmt.Errorf("TLS handshake with %s failed: %v", addr, err)
+}
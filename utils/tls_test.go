@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCA 测试用的自签名 CA，用它签发服务端和客户端证书
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &testCA{cert: cert, key: key, pool: pool}
+}
+
+// issue 签发 127.0.0.1 的服务端证书或客户端证书
+func (ca *testCA) issue(t *testing.T, client bool) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: "tikvtool-test"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if client {
+		tmpl.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+// serve 在本地端口上接受连接，每个连接交给 handle 处理，测试结束时关闭
+func serve(t *testing.T, ln net.Listener, handle func(conn net.Conn)) string {
+	t.Helper()
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// startTLS 模拟开启 mTLS 的 PD：只接受由 ca 签发的客户端证书
+func startTLS(t *testing.T, ca *testCA) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{ca.issue(t, false)},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    ca.pool,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, ln, func(conn net.Conn) {
+		if err := conn.(*tls.Conn).Handshake(); err != nil {
+			return
+		}
+		_, _ = io.Copy(io.Discard, conn)
+	})
+}
+
+// startPlain 模拟明文的 PD，收到任何数据都回复一个 HTTP 错误
+func startPlain(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return serve(t, ln, func(conn net.Conn) {
+		_, _ = conn.Read(make([]byte, 1024))
+		_, _ = conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+	})
+}
+
+func TestCheckTLS(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	other := newTestCA(t, "other CA")
+	clientCert := ca.issue(t, true)
+	tlsAddr := startTLS(t, ca)
+	plainAddr := startPlain(t)
+
+	tests := []struct {
+		name string
+		addr string
+		cfg  *tls.Config
+		want string // 错误中应包含的内容，为空表示握手成功
+	}{
+		{"ok", "https://" + tlsAddr, &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{clientCert}}, ""},
+		{"wrong CA", tlsAddr, &tls.Config{RootCAs: other.pool, Certificates: []tls.Certificate{clientCert}}, "not signed by the configured CA"},
+		{"plaintext server", plainAddr, &tls.Config{RootCAs: ca.pool}, "plain-text endpoint"},
+		{"missing client cert", tlsAddr, &tls.Config{RootCAs: ca.pool}, "client certificate"},
+		{"client cert from another CA", tlsAddr, &tls.Config{RootCAs: ca.pool, Certificates: []tls.Certificate{other.issue(t, true)}}, "client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckTLS(tt.addr, tt.cfg)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("CheckTLS: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("CheckTLS err = %v, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpeaksTLS(t *testing.T) {
+	ca := newTestCA(t, "test CA")
+	if !SpeaksTLS(startTLS(t, ca)) {
+		t.Error("SpeaksTLS = false for a TLS endpoint")
+	}
+	if SpeaksTLS(startPlain(t)) {
+		t.Error("SpeaksTLS = true for a plain-text endpoint")
+	}
+}
@@ -0,0 +1,169 @@
+package utils
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// ErrIncomplete 输入未结束：引号未闭合或行尾为续行符
+var ErrIncomplete = errors.New("incomplete input")
+
+// Token 命令行中的一个参数
+type Token struct {
+	Text   string // 去掉引号、处理转义后的内容
+	Raw    string // 输入中的原始写法
+	Start  int    // Raw 在整行中的起止位置
+	End    int
+	Quoted bool // 含引号、转义或来自 heredoc
+	// Enclosed 整个参数由一对引号包裹或来自 heredoc，如 'a b'；a'b'c 不算
+	Enclosed bool
+}
+
+// CommandLine 一条完整的命令
+type CommandLine struct {
+	Line   string
+	Tokens []Token
+}
+
+// Args 所有参数的文本
+func (cl *CommandLine) Args() []string {
+	args := make([]string, len(cl.Tokens))
+	for i, tok := range cl.Tokens {
+		args[i] = tok.Text
+	}
+	return args
+}
+
+// Tokenize 按 shell 规则拆分一行输入，这是命令参数中引号和转义的唯一规则：
+// 单引号内原样保留；双引号内只处理 \"、\\ 和 \xNN（一个字节，用于二进制 key），
+// 其余反斜杠原样保留，如 "C:\temp"、"\d+"；
+// 引号外反斜杠转义下一个字符，反斜杠加换行表示续行
+func Tokenize(line string) ([]Token, error) {
+	var tokens []Token
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return tokens, nil
+		}
+
+		tok := Token{Start: i}
+		var text strings.Builder
+		firstQuoteEnd := -1 // 以引号开头时第一段引号结束的位置
+		for i < len(line) && !isSpace(line[i]) {
+			switch ch := line[i]; ch {
+			case '\'':
+				end := strings.IndexByte(line[i+1:], '\'')
+				if end < 0 {
+					return nil, ErrIncomplete
+				}
+				text.WriteString(line[i+1 : i+1+end])
+				if i == tok.Start {
+					firstQuoteEnd = i + end + 2
+				}
+				i += end + 2
+				tok.Quoted = true
+			case '"':
+				i++
+				closed := false
+				for i < len(line) {
+					if line[i] == '"' {
+						closed = true
+						i++
+						break
+					}
+					if line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' {
+						if b, err := strconv.ParseUint(line[i+2:i+4], 16, 8); err == nil {
+							text.WriteByte(byte(b))
+							i += 4
+							continue
+						}
+					}
+					if line[i] == '\\' && i+1 < len(line) && (line[i+1] == '"' || line[i+1] == '\\') {
+						i++
+					}
+					text.WriteByte(line[i])
+					i++
+				}
+				if !closed {
+					return nil, ErrIncomplete
+				}
+				if line[tok.Start] == '"' && firstQuoteEnd < 0 {
+					firstQuoteEnd = i
+				}
+				tok.Quoted = true
+			case '\\':
+				if i+1 >= len(line) {
+					return nil, ErrIncomplete
+				}
+				if line[i+1] != '\n' {
+					text.WriteByte(line[i+1])
+				}
+				i += 2
+				tok.Quoted = true
+			default:
+				text.WriteByte(ch)
+				i++
+			}
+		}
+		tok.End = i
+		tok.Enclosed = firstQuoteEnd == tok.End
+		tok.Raw = line[tok.Start:tok.End]
+		tok.Text = text.String()
+		tokens = append(tokens, tok)
+	}
+}
+
+func isSpace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
+// ParseCommandLine 拆分单行命令，不支持续行和 heredoc
+func ParseCommandLine(line string) (*CommandLine, error) {
+	tokens, err := Tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandLine{Line: line, Tokens: tokens}, nil
+}
+
+// ReadCommand 以 first 为第一行读取一条完整命令。引号未闭合或行尾为反斜杠时
+// 通过 next 继续读取下一行；最后一个参数为 <<EOF 时读取 heredoc，
+// 直到单独一行 EOF 为止，heredoc 内容原样作为最后一个参数
+func ReadCommand(first string, next func() (string, error)) (*CommandLine, error) {
+	line := first
+	tokens, err := Tokenize(line)
+	for errors.Is(err, ErrIncomplete) {
+		more, nerr := next()
+		if nerr != nil {
+			return nil, nerr
+		}
+		line += "\n" + more
+		tokens, err = Tokenize(line)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if n := len(tokens); n > 0 && !tokens[n-1].Quoted && strings.HasPrefix(tokens[n-1].Raw, "<<") && len(tokens[n-1].Raw) > 2 {
+		delim := tokens[n-1].Raw[2:]
+		var body []string
+		for {
+			more, nerr := next()
+			if nerr != nil {
+				return nil, nerr
+			}
+			if strings.TrimRight(more, "\r") == delim {
+				break
+			}
+			body = append(body, more)
+		}
+		tokens[n-1].Text = strings.Join(body, "\n")
+		tokens[n-1].Quoted = true
+		tokens[n-1].Enclosed = true
+	}
+	return &CommandLine{Line: line, Tokens: tokens}, nil
+}
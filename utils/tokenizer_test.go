@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line     string
+		texts    []string
+		enclosed []bool
+	}{
+		{"get  a\tb", []string{"get", "a", "b"}, []bool{false, false, false}},
+		{"'a b' c", []string{"a b", "c"}, []bool{true, false}},
+		{`'a\"b'`, []string{`a\"b`}, []bool{true}},
+		{`"a \"b\" \\ \d \x00 \xzz"`, []string{"a \"b\" \\ \\d \x00 \\xzz"}, []bool{true}},
+		{`"C:\temp"`, []string{`C:\temp`}, []bool{true}},
+		{`a\ b`, []string{"a b"}, []bool{false}},
+		{`x'4f53'`, []string{"x4f53"}, []bool{false}},
+		{`a'b'c`, []string{"abc"}, []bool{false}},
+		{`'a'b`, []string{"ab"}, []bool{false}},
+		{`""`, []string{""}, []bool{true}},
+		{"  ", nil, nil},
+	}
+	for _, tt := range tests {
+		toks, err := Tokenize(tt.line)
+		if err != nil {
+			t.Errorf("Tokenize(%q): %v", tt.line, err)
+			continue
+		}
+		var texts []string
+		var enclosed []bool
+		for _, tok := range toks {
+			texts = append(texts, tok.Text)
+			enclosed = append(enclosed, tok.Enclosed)
+			if tok.Raw != tt.line[tok.Start:tok.End] {
+				t.Errorf("Tokenize(%q): Raw %q does not match [%d, %d)", tt.line, tok.Raw, tok.Start, tok.End)
+			}
+		}
+		if !reflect.DeepEqual(texts, tt.texts) || !reflect.DeepEqual(enclosed, tt.enclosed) {
+			t.Errorf("Tokenize(%q) = %q %v, want %q %v", tt.line, texts, enclosed, tt.texts, tt.enclosed)
+		}
+	}
+}
+
+func TestTokenizeIncomplete(t *testing.T) {
+	for _, line := range []string{`'abc`, `"abc`, `"abc\"`, `abc\`} {
+		if _, err := Tokenize(line); !errors.Is(err, ErrIncomplete) {
+			t.Errorf("Tokenize(%q) err = %v, want ErrIncomplete", line, err)
+		}
+	}
+}
+
+// lines 依次返回 more 中的行，读完后返回错误
+func lines(more ...string) func() (string, error) {
+	return func() (string, error) {
+		if len(more) == 0 {
+			return "", errors.New("eof")
+		}
+		line := more[0]
+		more = more[1:]
+		return line, nil
+	}
+}
+
+func TestReadCommand(t *testing.T) {
+	tests := []struct {
+		first string
+		more  []string
+		want  []string
+	}{
+		{"set k 'a", []string{"b'"}, []string{"set", "k", "a\nb"}},
+		{`ll OS/ \`, []string{"-limit=3"}, []string{"ll", "OS/", "-limit=3"}},
+		{"set k <<EOF", []string{`{"a": 1,`, `  "b": "x\y"}`, "EOF"}, []string{"set", "k", "{\"a\": 1,\n  \"b\": \"x\\y\"}"}},
+		{"set k <<END", []string{"EOF", "END\r"}, []string{"set", "k", "EOF"}},
+	}
+	for _, tt := range tests {
+		cl, err := ReadCommand(tt.first, lines(tt.more...))
+		if err != nil {
+			t.Errorf("ReadCommand(%q): %v", tt.first, err)
+			continue
+		}
+		if got := cl.Args(); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ReadCommand(%q) = %q, want %q", tt.first, got, tt.want)
+		}
+	}
+	if _, err := ReadCommand("set k <<EOF", lines("x")); err == nil {
+		t.Error("ReadCommand with an unterminated heredoc should fail")
+	}
+}
@@ -1,9 +1,8 @@
 package utils
 
 import (
+	"bytes"
 	"fmt"
-	"log"
-	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -19,50 +18,6 @@ func Int2Str(num int) string {
 	return strconv.Itoa(num)
 }
 
-func ContainLimit(strs []string) (bool, int) {
-	for _, str := range strs {
-		str = strings.ToLower(str)
-		if strings.Contains(str, "limit") {
-			ss := strings.Split(str, "limit=")
-			limit, _ := strconv.Atoi(ss[1])
-			return true, limit
-		}
-	}
-	return false, -1
-}
-
-func ContainPv(strs []string) bool {
-	for _, str := range strs {
-		str = strings.ToLower(str)
-		if strings.Contains(str, "pv") {
-			return true
-		}
-	}
-	return false
-}
-
-func ContainValue(strs []string) (bool, string) {
-	for _, str := range strs {
-		str = strings.ToLower(str)
-		if strings.Contains(str, "value") {
-			ss := strings.Split(str, "value=")
-
-			return true, ss[1]
-		}
-	}
-	return false, ""
-}
-
-func ContainNolog(strs []string) bool {
-	for _, str := range strs {
-		str = strings.ToLower(str)
-		if strings.Contains(str, "nolog") {
-			return true
-		}
-	}
-	return false
-}
-
 func IncrementLastCharASCII(s string) string {
 	if len(s) == 0 {
 		return s
@@ -93,26 +48,72 @@ func IncrementLastCharASCII(s string) string {
 	return string(b)
 }
 
-func InitLog() (*log.Logger, *os.File, error) {
-	now := time.Now()
-
-	logFileName := fmt.Sprintf("tikvcli-%04d%02d%02d.log",
-		now.Year(), now.Month(), now.Day())
+var sizeUnits = []string{"B", "KB", "MB", "GB", "TB"}
+
+// ParseSize 解析 512、64KB、1.5MB 这样的大小，单位按 1024 进位，不区分大小写
+func ParseSize(s string) (int64, error) {
+	t := strings.ToUpper(strings.TrimSpace(s))
+	mult := int64(1)
+	for i := len(sizeUnits) - 1; i > 0; i-- {
+		u := sizeUnits[i]
+		if strings.HasSuffix(t, u) || strings.HasSuffix(t, u[:1]) {
+			t = strings.TrimSuffix(strings.TrimSuffix(t, u), u[:1])
+			mult = int64(1) << (10 * i)
+			break
+		}
+	}
+	t = strings.TrimSuffix(t, "B")
+	n, err := strconv.ParseFloat(strings.TrimSpace(t), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use e.g. 512KB or 8MB", s)
+	}
+	return int64(n * float64(mult)), nil
+}
 
-	var logFile *os.File
-	var err error
+// FormatSize 以合适的单位显示字节数，如 1.5 MB
+func FormatSize(n float64) string {
+	i := 0
+	for n >= 1024 && i < len(sizeUnits)-1 {
+		n /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%.0f B", n)
+	}
+	return fmt.Sprintf("%.1f %s", n, sizeUnits[i])
+}
 
-	// 检查日志文件是否存在
-	if _, err := os.Stat(logFileName); err == nil {
-		// 文件存在，以读写和追加模式打开文件
-		logFile, err = os.OpenFile(logFileName, os.O_RDWR|os.O_APPEND, 0644)
-	} else if os.IsNotExist(err) {
-		// 文件不存在，创建文件
-		logFile, err = os.OpenFile(logFileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+// ParseDuration 在 time.ParseDuration 的基础上支持按天计的 d，如 3d、1d12h
+func ParseDuration(s string) (time.Duration, error) {
+	var days int64
+	rest := strings.TrimSpace(s)
+	if i := strings.IndexByte(rest, 'd'); i >= 0 {
+		n, err := strconv.ParseInt(rest[:i], 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %q, use e.g. 72h or 3d", s)
+		}
+		days, rest = n, rest[i+1:]
 	}
-	if err != nil {
-		return nil, logFile, fmt.Errorf("open log file err: %v", err)
+	d := time.Duration(days) * 24 * time.Hour
+	if rest != "" {
+		v, err := time.ParseDuration(rest)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid duration %q, use e.g. 72h or 3d", s)
+		}
+		d += v
 	}
+	return d, nil
+}
 
-	return log.New(logFile, " [INFO] ", log.LstdFlags), logFile, nil
+// PrefixEnd 以 prefix 开头的所有 key 的上界：最后一个不是 0xff 的字节加一并去掉其后的字节，
+// prefix 全为 0xff 时没有上界，返回 nil
+func PrefixEnd(prefix []byte) []byte {
+	end := bytes.Clone(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
 }
@@ -0,0 +1,598 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Where -where 过滤表达式，对 value 解析出的 JSON 求值，例如
+//
+//	.owner == "C003" && .lockTime < now() - 72h
+//
+// 支持：
+//
+//	.a .a.b .a[0]          取字段，不存在时为 null
+//	"s" 's' 12 1.5 true false null
+//	72h 30m 10s 500ms 3d   时长，按毫秒计，与 lockTime、maxDuration 的单位一致
+//	== != < <= > >= =~     =~ 右边为正则字符串
+//	&& || ! + - ( )
+//	now()                  当前时间的毫秒时间戳，整条命令内不变
+//	time("2025-07-01 10:00:00")  按 profile 时区转为毫秒时间戳
+//	len(x) lower(x) contains(s, sub)
+type Where struct {
+	src  string
+	root node
+}
+
+// ErrNotJSON value 不是 JSON 时 Match 返回的错误
+var ErrNotJSON = fmt.Errorf("value is not JSON")
+
+// ParseWhere 解析表达式
+func ParseWhere(src string) (*Where, error) {
+	toks, err := lexWhere(src)
+	if err != nil {
+		return nil, fmt.Errorf("-where: %v", err)
+	}
+	p := &whereParser{src: src, toks: toks, now: float64(time.Now().UnixMilli())}
+	root, err := p.parseOr()
+	if err == nil && p.peek().kind != tEOF {
+		err = p.errorf("unexpected %s", p.peek())
+	}
+	if err != nil {
+		return nil, fmt.Errorf("-where: %v", err)
+	}
+	return &Where{src: src, root: root}, nil
+}
+
+func (w *Where) String() string {
+	return w.src
+}
+
+// Match 解析 value 并求值，value 不是 JSON 时返回 ErrNotJSON
+func (w *Where) Match(value []byte) (bool, error) {
+	var doc interface{}
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return false, ErrNotJSON
+	}
+	return truthy(w.root.eval(doc)), nil
+}
+
+// ---- 词法 ----
+
+type tokKind int
+
+const (
+	tEOF tokKind = iota
+	tNum
+	tStr
+	tIdent
+	tPath
+	tOp
+)
+
+type whereTok struct {
+	kind tokKind
+	text string  // 运算符、标识符、路径或字符串内容
+	num  float64 // tNum 的值，时长已换算为毫秒
+	pos  int
+}
+
+func (t whereTok) String() string {
+	switch t.kind {
+	case tEOF:
+		return "end of expression"
+	case tStr:
+		return strconv.Quote(t.text)
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+var durationUnits = map[string]float64{
+	"ms": 1,
+	"s":  1000,
+	"m":  60 * 1000,
+	"h":  3600 * 1000,
+	"d":  24 * 3600 * 1000,
+}
+
+func lexWhere(src string) ([]whereTok, error) {
+	var toks []whereTok
+	i := 0
+	for {
+		for i < len(src) && unicode.IsSpace(rune(src[i])) {
+			i++
+		}
+		if i >= len(src) {
+			return append(toks, whereTok{kind: tEOF, pos: i}), nil
+		}
+		start := i
+		ch := src[i]
+		switch {
+		case ch == '"' || ch == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != ch {
+				if src[j] == '\\' && ch == '"' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string at column %d", start+1)
+			}
+			text := src[i+1 : j]
+			if ch == '"' {
+				s, err := strconv.Unquote(src[i : j+1])
+				if err != nil {
+					return nil, fmt.Errorf("invalid string at column %d: %v", start+1, err)
+				}
+				text = s
+			}
+			toks = append(toks, whereTok{kind: tStr, text: text, pos: start})
+			i = j + 1
+		case ch >= '0' && ch <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			n, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s at column %d", src[i:j], start+1)
+			}
+			k := j
+			for k < len(src) && unicode.IsLetter(rune(src[k])) {
+				k++
+			}
+			if unit := src[j:k]; unit != "" {
+				ms, ok := durationUnits[unit]
+				if !ok {
+					return nil, fmt.Errorf("unknown duration unit %q at column %d, use ms|s|m|h|d", unit, j+1)
+				}
+				n *= ms
+			}
+			toks = append(toks, whereTok{kind: tNum, text: src[i:k], num: n, pos: start})
+			i = k
+		case ch == '.':
+			j := i + 1
+			for j < len(src) && (isIdentChar(src[j]) || src[j] == '.' || src[j] == '[' || src[j] == ']') {
+				j++
+			}
+			toks = append(toks, whereTok{kind: tPath, text: src[i:j], pos: start})
+			i = j
+		case isIdentChar(ch):
+			j := i
+			for j < len(src) && isIdentChar(src[j]) {
+				j++
+			}
+			toks = append(toks, whereTok{kind: tIdent, text: src[i:j], pos: start})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"==", "!=", "<=", ">=", "=~", "&&", "||", "<", ">", "!", "(", ")", ",", "+", "-"} {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				if ch == '=' {
+					return nil, fmt.Errorf("unexpected '=' at column %d, use == to compare", start+1)
+				}
+				return nil, fmt.Errorf("unexpected %q at column %d", ch, start+1)
+			}
+			toks = append(toks, whereTok{kind: tOp, text: op, pos: start})
+			i += len(op)
+		}
+	}
+}
+
+func isIdentChar(ch byte) bool {
+	return ch == '_' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch >= '0' && ch <= '9'
+}
+
+// ---- 语法 ----
+
+type node interface {
+	eval(doc interface{}) interface{}
+}
+
+type whereParser struct {
+	src  string
+	toks []whereTok
+	i    int
+	now  float64
+}
+
+func (p *whereParser) peek() whereTok {
+	return p.toks[p.i]
+}
+
+func (p *whereParser) next() whereTok {
+	t := p.toks[p.i]
+	if t.kind != tEOF {
+		p.i++
+	}
+	return t
+}
+
+func (p *whereParser) isOp(op string) bool {
+	t := p.peek()
+	return t.kind == tOp && t.text == op
+}
+
+func (p *whereParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("%s at column %d: %s", fmt.Sprintf(format, args...), p.peek().pos+1, p.src)
+}
+
+func (p *whereParser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("expected %q, got %s", op, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+func (p *whereParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	for err == nil && p.isOp("||") {
+		p.next()
+		var right node
+		if right, err = p.parseAnd(); err == nil {
+			left = logicNode{op: "||", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	for err == nil && p.isOp("&&") {
+		p.next()
+		var right node
+		if right, err = p.parseNot(); err == nil {
+			left = logicNode{op: "&&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parseNot() (node, error) {
+	if p.isOp("!") {
+		p.next()
+		n, err := p.parseNot()
+		return notNode{n}, err
+	}
+	return p.parseCmp()
+}
+
+func (p *whereParser) parseCmp() (node, error) {
+	left, err := p.parseSum()
+	if err != nil {
+		return nil, err
+	}
+	t := p.peek()
+	if t.kind != tOp {
+		return left, nil
+	}
+	switch t.text {
+	case "=~":
+		p.next()
+		lit := p.next()
+		if lit.kind != tStr {
+			return nil, fmt.Errorf("=~ needs a regular expression string at column %d: %s", lit.pos+1, p.src)
+		}
+		re, err := regexp.Compile(lit.text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at column %d: %v", lit.pos+1, err)
+		}
+		return regexNode{left: left, re: re}, nil
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		right, err := p.parseSum()
+		if err != nil {
+			return nil, err
+		}
+		return cmpNode{op: t.text, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseSum() (node, error) {
+	left, err := p.parsePrimary()
+	for err == nil && (p.isOp("+") || p.isOp("-")) {
+		op := p.next().text
+		var right node
+		if right, err = p.parsePrimary(); err == nil {
+			left = arithNode{op: op, left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (p *whereParser) parsePrimary() (node, error) {
+	t := p.peek()
+	switch t.kind {
+	case tNum:
+		p.next()
+		return constNode{t.num}, nil
+	case tStr:
+		p.next()
+		return constNode{t.text}, nil
+	case tPath:
+		p.next()
+		return parsePath(t)
+	case tIdent:
+		p.next()
+		switch t.text {
+		case "true":
+			return constNode{true}, nil
+		case "false":
+			return constNode{false}, nil
+		case "null":
+			return constNode{nil}, nil
+		}
+		return p.parseCall(t)
+	case tOp:
+		switch t.text {
+		case "(":
+			p.next()
+			n, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "-":
+			p.next()
+			n, err := p.parsePrimary()
+			return arithNode{op: "-", left: constNode{0.0}, right: n}, err
+		}
+	}
+	return nil, p.errorf("unexpected %s", t)
+}
+
+func (p *whereParser) parseCall(name whereTok) (node, error) {
+	if err := p.expect("("); err != nil {
+		return nil, fmt.Errorf("unknown name %q at column %d, fields start with a dot, e.g. .%s: %s", name.text, name.pos+1, name.text, p.src)
+	}
+	var args []node
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+
+	argc := map[string]int{"now": 0, "time": 1, "len": 1, "lower": 1, "contains": 2}
+	want, ok := argc[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s() at column %d, use now|time|len|lower|contains", name.text, name.pos+1)
+	}
+	if len(args) != want {
+		return nil, fmt.Errorf("%s() takes %d argument(s) at column %d", name.text, want, name.pos+1)
+	}
+	switch name.text {
+	case "now":
+		return constNode{p.now}, nil
+	case "time":
+		lit, ok := args[0].(constNode)
+		s, isStr := lit.v.(string)
+		if !ok || !isStr {
+			return nil, fmt.Errorf("time() needs a string like \"2006-01-02 15:04:05\" at column %d", name.pos+1)
+		}
+		t, err := time.ParseInLocation("2006-01-02 15:04:05", s, cst)
+		if err != nil {
+			return nil, fmt.Errorf("time(%q) at column %d: %v", s, name.pos+1, err)
+		}
+		return constNode{float64(t.UnixMilli())}, nil
+	}
+	return callNode{name: name.text, args: args}, nil
+}
+
+// parsePath 解析 .a.b[0]，单独的 . 表示整个文档
+func parsePath(t whereTok) (node, error) {
+	var steps []interface{}
+	rest := t.text
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			j := 1
+			for j < len(rest) && isIdentChar(rest[j]) {
+				j++
+			}
+			if j > 1 {
+				steps = append(steps, rest[1:j])
+			} else if len(rest) > 1 && rest[1] != '[' {
+				return nil, fmt.Errorf("invalid field %s at column %d", t.text, t.pos+1)
+			}
+			rest = rest[j:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("missing ] in %s at column %d", t.text, t.pos+1)
+			}
+			idx, err := strconv.Atoi(rest[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("invalid index in %s at column %d", t.text, t.pos+1)
+			}
+			steps = append(steps, idx)
+			rest = rest[end+1:]
+		default:
+			return nil, fmt.Errorf("invalid field %s at column %d", t.text, t.pos+1)
+		}
+	}
+	return pathNode{steps}, nil
+}
+
+// ---- 求值 ----
+
+type constNode struct{ v interface{} }
+
+func (n constNode) eval(interface{}) interface{} { return n.v }
+
+type pathNode struct{ steps []interface{} }
+
+func (n pathNode) eval(doc interface{}) interface{} {
+	v := doc
+	for _, step := range n.steps {
+		switch s := step.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil
+			}
+			v = m[s]
+		case int:
+			a, ok := v.([]interface{})
+			if !ok || s < 0 || s >= len(a) {
+				return nil
+			}
+			v = a[s]
+		}
+	}
+	return v
+}
+
+type logicNode struct {
+	op          string
+	left, right node
+}
+
+func (n logicNode) eval(doc interface{}) interface{} {
+	l := truthy(n.left.eval(doc))
+	if n.op == "&&" {
+		return l && truthy(n.right.eval(doc))
+	}
+	return l || truthy(n.right.eval(doc))
+}
+
+type notNode struct{ n node }
+
+func (n notNode) eval(doc interface{}) interface{} { return !truthy(n.n.eval(doc)) }
+
+type cmpNode struct {
+	op          string
+	left, right node
+}
+
+// eval 数字和字符串可比较大小，类型不同时只有 != 成立
+func (n cmpNode) eval(doc interface{}) interface{} {
+	l, r := n.left.eval(doc), n.right.eval(doc)
+	switch n.op {
+	case "==":
+		return reflect.DeepEqual(l, r)
+	case "!=":
+		return !reflect.DeepEqual(l, r)
+	}
+	var c int
+	switch lv := l.(type) {
+	case float64:
+		rv, ok := r.(float64)
+		if !ok {
+			return false
+		}
+		switch {
+		case lv < rv:
+			c = -1
+		case lv > rv:
+			c = 1
+		}
+	case string:
+		rv, ok := r.(string)
+		if !ok {
+			return false
+		}
+		c = strings.Compare(lv, rv)
+	default:
+		return false
+	}
+	switch n.op {
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
+
+type regexNode struct {
+	left node
+	re   *regexp.Regexp
+}
+
+func (n regexNode) eval(doc interface{}) interface{} {
+	s, ok := n.left.eval(doc).(string)
+	return ok && n.re.MatchString(s)
+}
+
+type arithNode struct {
+	op          string
+	left, right node
+}
+
+func (n arithNode) eval(doc interface{}) interface{} {
+	l, lok := n.left.eval(doc).(float64)
+	r, rok := n.right.eval(doc).(float64)
+	if !lok || !rok {
+		return nil
+	}
+	if n.op == "+" {
+		return l + r
+	}
+	return l - r
+}
+
+type callNode struct {
+	name string
+	args []node
+}
+
+func (n callNode) eval(doc interface{}) interface{} {
+	v := n.args[0].eval(doc)
+	switch n.name {
+	case "len":
+		switch x := v.(type) {
+		case string:
+			return float64(len([]rune(x)))
+		case []interface{}:
+			return float64(len(x))
+		case map[string]interface{}:
+			return float64(len(x))
+		}
+		return nil
+	case "lower":
+		if s, ok := v.(string); ok {
+			return strings.ToLower(s)
+		}
+		return nil
+	case "contains":
+		s, ok1 := v.(string)
+		sub, ok2 := n.args[1].eval(doc).(string)
+		return ok1 && ok2 && strings.Contains(s, sub)
+	}
+	return nil
+}
+
+// truthy false 和 null 为假，其余为真
+func truthy(v interface{}) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	}
+	return true
+}
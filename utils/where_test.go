@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWhereMatch(t *testing.T) {
+	now := time.Now().UnixMilli()
+	day := int64(24 * time.Hour / time.Millisecond)
+	lock := fmt.Sprintf(`{"owner":"C003","maxDuration":259200000,"lockTime":%d,"tags":["a","b"],"meta":{"name":"Lock"},"flag":true,"none":null}`, now-4*day)
+	at := time.Date(2025, 7, 1, 10, 0, 0, 0, cst).UnixMilli()
+
+	tests := []struct {
+		expr  string
+		value string
+		want  bool
+	}{
+		// 字段和字面量
+		{`.owner == "C003"`, lock, true},
+		{`.owner == 'C003'`, lock, true},
+		{`.owner != "C003"`, lock, false},
+		{`.tags[1] == "b"`, lock, true},
+		{`.tags[5] == null`, lock, true},
+		{`.meta.name == "Lock"`, lock, true},
+		{`.missing == null`, lock, true},
+		{`.flag`, lock, true},
+		{`.none`, lock, false},
+		{`. == 3`, `3`, true},
+		{`.[0] == 1`, `[1,2]`, true},
+
+		// 优先级：! 高于 &&，&& 高于 ||，比较高于逻辑运算，+ - 左结合
+		{`true || false && false`, lock, true},
+		{`(true || false) && false`, lock, false},
+		{`!false && false`, lock, false},
+		{`!(false && false)`, lock, true},
+		{`.owner == "C003" || .owner == "C007" && .flag == false`, lock, true},
+		{`1 + 2 - 3 == 0`, lock, true},
+		{`10 - 2 - 3 == 5`, lock, true},
+		{`-1 + 2 == 1`, lock, true},
+		{`!.missing`, lock, true},
+
+		// 时长按毫秒计
+		{`.maxDuration == 72h`, lock, true},
+		{`.maxDuration == 3d`, lock, true},
+		{`.maxDuration > 4320m`, lock, false},
+		{`1s == 1000 && 500ms == 0.5s && 1.5h == 90m`, lock, true},
+
+		// now() 和 time()
+		{`.lockTime < now() - 72h`, lock, true},
+		{`.lockTime < now() - 5d`, lock, false},
+		{`.lockTime + .maxDuration < now()`, lock, true},
+		{fmt.Sprintf(`time("2025-07-01 10:00:00") == %d`, at), lock, true},
+
+		// 类型不同时比较不成立，只有 != 成立
+		{`.owner < 3`, lock, false},
+		{`.owner >= 3`, lock, false},
+		{`.maxDuration == "259200000"`, lock, false},
+		{`.maxDuration != "259200000"`, lock, true},
+		{`.owner + 1 == null`, lock, true},
+		{`.flag > false`, lock, false},
+		{`.tags =~ "a"`, lock, false},
+		{`"b" > "a"`, lock, true},
+
+		// 函数和正则
+		{`len(.tags) == 2 && len(.owner) == 4 && len(.meta) == 1`, lock, true},
+		{`len(.maxDuration) == null`, lock, true},
+		{`lower(.owner) == "c003"`, lock, true},
+		{`contains(.owner, "00")`, lock, true},
+		{`contains(.maxDuration, "00")`, lock, false},
+		{`.owner =~ "^C00[37]$"`, lock, true},
+		{`.owner =~ "^c"`, lock, false},
+		{`.meta.name =~ "\\bLock\\b"`, lock, true},
+	}
+	for _, tt := range tests {
+		w, err := ParseWhere(tt.expr)
+		if err != nil {
+			t.Errorf("ParseWhere(%q): %v", tt.expr, err)
+			continue
+		}
+		got, err := w.Match([]byte(tt.value))
+		if err != nil {
+			t.Errorf("%q.Match: %v", tt.expr, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q.Match(%s) = %v, want %v", tt.expr, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWhereNotJSON(t *testing.T) {
+	w, err := ParseWhere(`.owner == "C003"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"", "C003", `{"owner":`} {
+		if _, err := w.Match([]byte(value)); err != ErrNotJSON {
+			t.Errorf("Match(%q) err = %v, want ErrNotJSON", value, err)
+		}
+	}
+}
+
+func TestParseWhereErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string // 错误中应包含的内容
+	}{
+		{``, "unexpected end of expression"},
+		{`.owner ==`, "unexpected end of expression"},
+		{`.owner = "C003"`, "use == to compare"},
+		{`(.owner == "C003"`, `expected ")"`},
+		{`.owner == "C003")`, `unexpected ")"`},
+		{`.owner .lockTime`, "unexpected"},
+		{`owner == "C003"`, "fields start with a dot"},
+		{`.owner == "C003`, "unterminated string"},
+		{`.owner == 'C003`, "unterminated string"},
+		{`.lockTime < 3w`, "unknown duration unit"},
+		{`.lockTime < 1.2.3`, "invalid number"},
+		{`.a..b == 1`, "invalid field"},
+		{`.a[x] == 1`, "invalid index"},
+		{`.a[0 == 1`, "missing ]"},
+		{`.owner =~ 3`, "needs a regular expression string"},
+		{`.owner =~ "["`, "invalid regular expression"},
+		{`upper(.owner) == "C003"`, "unknown function upper()"},
+		{`now(1) > 0`, "takes 0 argument(s)"},
+		{`contains(.owner) `, "takes 2 argument(s)"},
+		{`.lockTime < time(.x)`, "time() needs a string"},
+		{`.lockTime < time("2025-07-01")`, `time("2025-07-01")`},
+		{`.owner == "C003" # x`, `unexpected '#'`},
+	}
+	for _, tt := range tests {
+		_, err := ParseWhere(tt.expr)
+		if err == nil {
+			t.Errorf("ParseWhere(%q) should fail", tt.expr)
+			continue
+		}
+		if !strings.HasPrefix(err.Error(), "-where: ") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("ParseWhere(%q) err = %v, want it to mention %q", tt.expr, err, tt.want)
+		}
+	}
+}